@@ -0,0 +1,24 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// AuthConfig holds the credentials used to authenticate against a registry.
+type AuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Email         string `json:"email,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// header returns the X-Registry-Auth header carrying the auth config.
+func (a AuthConfig) header() (http.Header, error) {
+	buf, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return http.Header{"X-Registry-Auth": {base64.URLEncoding.EncodeToString(buf)}}, nil
+}
@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// BuildOptions controls how BuildImage builds an image.
+type BuildOptions struct {
+	Tags       []string
+	Dockerfile string
+	NoCache    bool
+	BuildArgs  map[string]string
+	Pull       bool
+}
+
+func (o BuildOptions) values() (url.Values, error) {
+	v := url.Values{}
+	for _, tag := range o.Tags {
+		v.Add("t", tag)
+	}
+	if o.Dockerfile != "" {
+		v.Set("dockerfile", o.Dockerfile)
+	}
+	if o.NoCache {
+		v.Set("nocache", "1")
+	}
+	if o.Pull {
+		v.Set("pull", "1")
+	}
+	if len(o.BuildArgs) > 0 {
+		args, err := json.Marshal(o.BuildArgs)
+		if err != nil {
+			return nil, err
+		}
+		v.Set("buildargs", string(args))
+	}
+	return v, nil
+}
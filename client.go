@@ -2,51 +2,110 @@ package docker
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"os"
-	"os/signal"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
+	"time"
 )
 
 type (
+	// Docker is a client of the Docker Engine API. It is safe for concurrent
+	// use by multiple goroutines.
 	Docker interface {
-		FetchAllContainers(all bool) ([]*Container, error)
-		FetchContainer(name string) (*Container, error)
-		GetEvents() chan *Event
-		Info() (*DaemonInfo, error)
-		PullImage(name string) error
-		CreateContainer(container map[string]interface{}) (string, error)
-		StartContainer(string, interface{}) error
-		RunContainer(map[string]interface{}) (string, error)
-		RemoveContainer(name string, force, volumes bool) error
-		ContainerLogs(id string, follow, stdout, stderr, timestamps bool, tail int) (io.ReadCloser, error)
-		ContainerPause(id string) error
-		ContainerUnpause(id string) error
-		Copy(id string, file string) (io.ReadCloser, error)
-		Build(ctx io.Reader, tag string, nocache bool, forcerm bool) (io.ReadCloser, error)
+		FetchAllContainers(ctx context.Context, all bool) ([]*ContainerSummary, error)
+		ListContainers(ctx context.Context, opts ListOptions) ([]*ContainerSummary, error)
+		FetchContainer(ctx context.Context, name string) (*Container, error)
+		InspectContainer(ctx context.Context, name string, size bool) (*Container, error)
+		ContainerPort(ctx context.Context, name, containerPort string) ([]Binding, error)
+		WaitHealthy(ctx context.Context, name string, timeout time.Duration) error
+		WaitRunning(ctx context.Context, name string, timeout time.Duration) (*Container, error)
+		GetEvents(ctx context.Context) (<-chan *Event, <-chan error, error)
+		GetEventsFiltered(ctx context.Context, opts EventOptions) (<-chan *Event, <-chan error, error)
+		GetContainerEvents(ctx context.Context) (<-chan *Event, <-chan error, error)
+		GetEventsReconnect(ctx context.Context) (<-chan *Event, <-chan error)
+		Info(ctx context.Context) (*DaemonInfo, error)
+		Ping(ctx context.Context) error
+		PullImage(ctx context.Context, name string) error
+		PullImageAuth(ctx context.Context, name string, auth AuthConfig) error
+		PullImageProgress(ctx context.Context, name string, auth *AuthConfig) (<-chan PullStatus, <-chan error, error)
+		ListImages(ctx context.Context, all bool) ([]*Image, error)
+		InspectImage(ctx context.Context, name string) (*ImageInfo, error)
+		ImageExists(ctx context.Context, name string) (bool, error)
+		ImageHistory(ctx context.Context, name string) ([]HistoryLayer, error)
+		TagImage(ctx context.Context, name, repo, tag string) error
+		PushImage(ctx context.Context, name string, auth AuthConfig) (<-chan PushStatus, <-chan error, error)
+		SearchImages(ctx context.Context, term string) ([]SearchResult, error)
+		CreateContainer(ctx context.Context, container map[string]interface{}) (string, error)
+		CreateContainerWithConfig(ctx context.Context, cfg ContainerConfig, host HostConfig, name string) (string, error)
+		StartContainer(context.Context, string, interface{}) error
+		RunContainer(context.Context, map[string]interface{}) (string, error)
+		RunContainerWithConfig(ctx context.Context, cfg ContainerConfig, host HostConfig, name string) (string, error)
+		RunAndWait(ctx context.Context, cfg ContainerConfig, host HostConfig, timeout time.Duration, remove bool) (int, error)
+		StopContainer(ctx context.Context, name string, timeout int) error
+		RestartContainer(ctx context.Context, name string, timeout int) error
+		KillContainer(ctx context.Context, name, signal string) error
+		RemoveContainer(ctx context.Context, name string, force, volumes bool) error
+		ContainerLogs(ctx context.Context, id string, follow, stdout, stderr, timestamps bool, tail int, since, until int64) (io.ReadCloser, error)
+		ContainerLogsRaw(ctx context.Context, id string, opts LogOptions) (io.ReadCloser, error)
+		ContainerLogsAll(ctx context.Context, id string, stdout, stderr, timestamps bool, tail int) (string, error)
+		ContainerLogsOpts(ctx context.Context, id string, opts LogOptions) (<-chan *LogLine, <-chan error, error)
+		ContainerLogLines(ctx context.Context, id string, follow, stdout, stderr, timestamps bool, tail int, since, until int64) (<-chan *LogLine, <-chan error, error)
+		PauseContainer(ctx context.Context, name string) error
+		UnpauseContainer(ctx context.Context, name string) error
+		ContainerPause(ctx context.Context, id string) error
+		ContainerUnpause(ctx context.Context, id string) error
+		Copy(ctx context.Context, id string, file string) (io.ReadCloser, error)
+		CopyToContainer(ctx context.Context, id, path string, content io.Reader, noOverwriteDirNonDir bool) error
+		CopyFromContainer(ctx context.Context, id, path string) (io.ReadCloser, error)
+		BuildImage(ctx context.Context, buildContext io.Reader, opts BuildOptions) (<-chan string, <-chan error, error)
+		Build(ctx context.Context, buildContext io.Reader, tag string, nocache bool, forcerm bool) (io.ReadCloser, error)
 		DecodeStream(stream io.Reader) []string
-		RemoveImage(name string, force bool, noprune bool) (io.ReadCloser, error)
-		ContainerWait(name string) error
+		RemoveImage(ctx context.Context, name string, force bool, noprune bool) ([]ImageDeleteResponse, error)
+		WaitContainer(ctx context.Context, name string) (int, error)
+		WaitForExit(ctx context.Context, name string, timeout time.Duration) (int, error)
+		ContainerWait(ctx context.Context, name string) error
 		SetTlsConfig(config *tls.Config)
-		Version() (*DaemonVersion, error)
-		ContainerStats(name string) (io.ReadCloser, error)
-		//Attach(name string, logs, stream, stdin, stdout, stderr bool) (io.Reader, io.Writer, error)
-	}
-
-	Event struct {
-		ContainerId string `json:"id"`
-		Status      string `json:"status"`
+		SetRetryPolicy(policy RetryPolicy)
+		Close() error
+		Do(ctx context.Context, method, path string, body interface{}) (*http.Response, error)
+		Version(ctx context.Context) (*DaemonVersion, error)
+		DiskUsage(ctx context.Context) (*DiskUsageReport, error)
+		ContainerStats(ctx context.Context, name string, stream bool) (<-chan *Stats, <-chan error, error)
+		RenameContainer(ctx context.Context, name, newName string) error
+		UpdateContainer(ctx context.Context, name string, resources UpdateConfig) error
+		ContainerTop(ctx context.Context, id string, psArgs string) (*TopResult, error)
+		ContainerDiff(ctx context.Context, id string) ([]FileChange, error)
+		CommitContainer(ctx context.Context, id string, opts CommitOptions) (string, error)
+		ExportContainer(ctx context.Context, id string) (io.ReadCloser, error)
+		ImportImage(ctx context.Context, src io.Reader, repo, tag string) (string, error)
+		CreateVolume(ctx context.Context, opts VolumeOptions) (*VolumeInfo, error)
+		ListVolumes(ctx context.Context) ([]*VolumeInfo, error)
+		InspectVolume(ctx context.Context, name string) (*VolumeInfo, error)
+		RemoveVolume(ctx context.Context, name string, force bool) error
+		CreateNetwork(ctx context.Context, opts NetworkOptions) (string, error)
+		ListNetworks(ctx context.Context) ([]*Network, error)
+		ConnectNetwork(ctx context.Context, networkId, containerId string) error
+		DisconnectNetwork(ctx context.Context, networkId, containerId string, force bool) error
+		PruneContainers(ctx context.Context, filters map[string][]string) (*PruneReport, error)
+		PruneImages(ctx context.Context, filters map[string][]string) (*PruneReport, error)
+		PruneVolumes(ctx context.Context, filters map[string][]string) (*PruneReport, error)
+		ExecCreate(ctx context.Context, container string, cmd []string, opts ExecConfig) (string, error)
+		ExecStart(ctx context.Context, execId string, detach bool) (io.ReadCloser, error)
+		ExecInspect(ctx context.Context, execId string) (*ExecInspectResult, error)
+		ContainerExecs(ctx context.Context, id string) ([]*ExecInspectResult, error)
+		ResizeContainerTTY(ctx context.Context, id string, height, width int) error
+		ResizeExecTTY(ctx context.Context, execId string, height, width int) error
+		AttachContainer(ctx context.Context, id string, opts AttachOptions) (io.ReadWriteCloser, error)
 	}
 
 	Binding struct {
@@ -57,15 +116,50 @@ type (
 	NetworkSettings struct {
 		IpAddress string
 		Ports     map[string][]Binding
+		// Networks holds the endpoints of the container keyed by network
+		// name. IpAddress is only set for the default bridge network.
+		Networks map[string]EndpointSettings
+	}
+
+	// EndpointSettings is the connection of a container to a network.
+	EndpointSettings struct {
+		NetworkID  string
+		EndpointID string
+		Gateway    string
+		IPAddress  string
+		MacAddress string
 	}
 
+	// DialFunc opens a connection to the daemon, see WithDialer.
+	DialFunc func(network, addr string) (net.Conn, error)
+
+	// dockerClient is safe for concurrent use by multiple goroutines. Its
+	// settings are fixed at construction, except for those changed through
+	// SetTlsConfig and SetRetryPolicy which are guarded by mu.
 	dockerClient struct {
-		path      string
+		path    string
+		dial    DialFunc
+		client  *http.Client
+		version string
+		timeout time.Duration
+
+		mu        sync.RWMutex
 		tlsConfig *tls.Config
+		retry     RetryPolicy
+
+		// closed is cancelled by Close to stop background goroutines.
+		closed context.Context
+		close  context.CancelFunc
+		// cleanup, if set, is run by Close to release what the client
+		// was built on, such as the server of NewTestClient.
+		cleanup func()
 	}
 
 	DaemonInfo struct {
 		Containers         int
+		ContainersRunning  int
+		ContainersPaused   int
+		ContainersStopped  int
 		Images             int
 		Driver             string
 		DriverStatus       [][]string
@@ -75,23 +169,30 @@ type (
 		MemTotal           int64
 		Name               string
 		ID                 string
-		Debug              int
+		Debug              bool
 		NFd                int
 		NGoroutines        int
 		NEventsListener    int
 		InitPath           string
 		InitSha1           string
 		IndexServerAddress string
-		MemoryLimit        int
-		SwapLimit          int
-		IPv4Forwarding     int
+		MemoryLimit        bool
+		SwapLimit          bool
+		IPv4Forwarding     bool
 		Labels             []string
 		DockerRootDir      string
 		OperatingSystem    string
+		OSType             string
+		Architecture       string
+		ServerVersion      string
+		// Warnings lists problems with the daemon's setup, e.g. a missing
+		// kernel feature.
+		Warnings []string
 	}
 
 	DaemonVersion struct {
 		ApiVersion    string
+		MinAPIVersion string
 		Arch          string
 		GitCommit     string
 		GoVersion     string
@@ -110,491 +211,2394 @@ func (d *DaemonInfo) RootPath() string {
 	return ""
 }
 
-func NewClient(path string) (Docker, error) {
-	return &dockerClient{path: path}, nil
+// UnmarshalJSON accepts the flags of DaemonInfo both as booleans and as the
+// 0/1 integers sent by older daemons.
+func (d *DaemonInfo) UnmarshalJSON(data []byte) error {
+	type info DaemonInfo
+	aux := struct {
+		*info
+		Debug          json.RawMessage
+		MemoryLimit    json.RawMessage
+		SwapLimit      json.RawMessage
+		IPv4Forwarding json.RawMessage
+	}{info: (*info)(d)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	for _, flag := range []struct {
+		name string
+		raw  json.RawMessage
+		dst  *bool
+	}{
+		{"Debug", aux.Debug, &d.Debug},
+		{"MemoryLimit", aux.MemoryLimit, &d.MemoryLimit},
+		{"SwapLimit", aux.SwapLimit, &d.SwapLimit},
+		{"IPv4Forwarding", aux.IPv4Forwarding, &d.IPv4Forwarding},
+	} {
+		switch string(flag.raw) {
+		case "true", "1":
+			*flag.dst = true
+		case "", "null", "false", "0":
+			*flag.dst = false
+		default:
+			return fmt.Errorf("invalid value for %s: %s", flag.name, flag.raw)
+		}
+	}
+	return nil
+}
+
+// NewClient returns a client for the daemon at path, see ParseURL for the
+// accepted forms, set up by opts. An unsupported scheme or a unix socket which
+// does not exist is reported right away, unless the connections are made by a
+// custom dialer or transport. https endpoints are verified against the system
+// roots unless a TLS config is given.
+func NewClient(path string, opts ...ClientOption) (Docker, error) {
+	d := newDockerClient(path)
+	transport := d.client.Transport
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.dial == nil && d.client.Transport == transport {
+		if err := checkEndpoint(path); err != nil {
+			return nil, err
+		}
+	}
+	if d.tlsConfig == nil && strings.HasPrefix(path, "https://") {
+		d.tlsConfig = &tls.Config{}
+	}
+	return d, nil
+}
+
+// NewTLSClient is NewClient with WithTLSConfig.
+func NewTLSClient(path string, config *tls.Config) (Docker, error) {
+	return NewClient(path, WithTLSConfig(config))
+}
+
+// NewClientWithDialer is NewClient with WithDialer.
+func NewClientWithDialer(path string, dial DialFunc) (Docker, error) {
+	return NewClient(path, WithDialer(dial))
+}
+
+// NewClientWithTransport is NewClient with WithTransport.
+func NewClientWithTransport(path string, transport http.RoundTripper) (Docker, error) {
+	return NewClient(path, WithTransport(transport))
+}
+
+// NewClientVersion is NewClient with WithVersion.
+func NewClientVersion(path, version string) (Docker, error) {
+	return NewClient(path, WithVersion(version))
+}
+
+// NewClientTimeout is NewClient with WithTimeout.
+func NewClientTimeout(path string, timeout time.Duration) (Docker, error) {
+	return NewClient(path, WithTimeout(timeout))
+}
+
+// newDockerClient sets up a client whose connections are pooled and reused
+// across requests.
+func newDockerClient(path string) *dockerClient {
+	d := &dockerClient{path: path}
+	d.client = &http.Client{
+		Transport: &http.Transport{DialContext: d.dialContext},
+	}
+	d.closed, d.close = context.WithCancel(context.Background())
+	return d
+}
+
+// Close stops the client's background goroutines, such as the loop of
+// GetEventsReconnect, and closes its idle connections. Requests in flight are
+// not interrupted; cancel their context for that. Close may be called more
+// than once.
+func (d *dockerClient) Close() error {
+	d.close()
+	d.client.CloseIdleConnections()
+	if d.cleanup != nil {
+		d.cleanup()
+	}
+	return nil
 }
 
+// SetTlsConfig replaces the TLS config used for new connections.
 func (d *dockerClient) SetTlsConfig(config *tls.Config) {
+	d.mu.Lock()
 	d.tlsConfig = config
+	d.mu.Unlock()
+	// Pooled connections were set up with the old config.
+	d.client.CloseIdleConnections()
+}
+
+// SetRetryPolicy sets how requests failing for transient reasons, such as
+// the daemon reloading, are retried.
+func (d *dockerClient) SetRetryPolicy(policy RetryPolicy) {
+	d.mu.Lock()
+	d.retry = policy
+	d.mu.Unlock()
+}
+
+func (d *dockerClient) getTlsConfig() *tls.Config {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.tlsConfig
 }
 
-func (d *dockerClient) newConn() (*httputil.ClientConn, error) {
+func (d *dockerClient) getRetryPolicy() RetryPolicy {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.retry
+}
+
+// dialContext connects to the daemon. The network and address asked for by
+// the transport are ignored in favour of the client's path.
+func (d *dockerClient) dialContext(ctx context.Context, _, _ string) (net.Conn, error) {
 	var (
-		conn net.Conn
-		err  error
+		conn      net.Conn
+		err       error
+		dialer    net.Dialer
+		tlsConfig = d.getTlsConfig()
 	)
 	proto, path := ParseURL(d.path)
-	if d.tlsConfig == nil {
-		conn, err = net.Dial(proto, path)
-	} else {
-		conn, err = tls.Dial(proto, path, d.tlsConfig)
+	switch {
+	case d.dial != nil:
+		conn, err = d.dial(proto, path)
+		if err == nil && tlsConfig != nil {
+			conn, err = tlsClient(ctx, conn, path, tlsConfig)
+		}
+	case tlsConfig == nil:
+		conn, err = dialer.DialContext(ctx, proto, path)
+	default:
+		tlsDialer := &tls.Dialer{NetDialer: &dialer, Config: tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, proto, path)
 	}
 
 	if err != nil {
+		if proto == "unix" && errors.Is(err, os.ErrPermission) {
+			return nil, fmt.Errorf("permission denied on docker socket %s, the user needs to be root or in the docker group: %w", path, err)
+		}
 		return nil, err
 	}
-	return httputil.NewClientConn(conn, nil), nil
+	return conn, nil
 }
 
-func (docker *dockerClient) PullImage(name string) error {
-	var (
-		method = "POST"
-		uri    = fmt.Sprintf("/images/create?fromImage=%s", name)
-	)
+// tlsClient runs the TLS handshake over an already established conn.
+func tlsClient(ctx context.Context, conn net.Conn, addr string, config *tls.Config) (net.Conn, error) {
+	if config.ServerName == "" {
+		config = config.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			config.ServerName = host
+		} else {
+			config.ServerName = addr
+		}
+	}
 
-	respBody, err := docker.newRequest(method, uri, nil)
-	if err != nil {
-		return nil
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
 	}
+	return tlsConn, nil
+}
 
-	respBody.Close()
+func (docker *dockerClient) PullImage(ctx context.Context, name string) error {
+	return docker.pullImage(ctx, name, "", nil)
+}
 
-	return nil
+// PullImageAuth is like PullImage but authenticates against the registry with
+// auth, which is needed to pull private images.
+func (docker *dockerClient) PullImageAuth(ctx context.Context, name string, auth AuthConfig) error {
+	header, err := auth.header()
+	if err != nil {
+		return err
+	}
+	return docker.pullImage(ctx, name, "", header)
 }
 
-func (docker *dockerClient) RemoveContainer(name string, force, volumes bool) error {
-	var (
-		method = "DELETE"
-		uri    = fmt.Sprintf("/containers/%s?force=%s&volumes=%s", name, strconv.FormatBool(force), strconv.FormatBool(volumes))
-	)
+// PullImageProgress is like PullImage but streams the progress of the pull.
+// auth may be nil for public images. If the pull fails, the error is
+// delivered on the error channel before both channels are closed.
+func (docker *dockerClient) PullImageProgress(ctx context.Context, name string, auth *AuthConfig) (<-chan PullStatus, <-chan error, error) {
+	var header http.Header
+	if auth != nil {
+		var err error
+		if header, err = auth.header(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	respBody, err := docker.startPull(ctx, name, "", header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statuses, errChan := streamProgress(ctx, respBody)
+	return statuses, errChan, nil
+}
 
-	respBody, err := docker.newRequest(method, uri, nil)
+func (docker *dockerClient) pullImage(ctx context.Context, name, platform string, header http.Header) error {
+	respBody, err := docker.startPull(ctx, name, platform, header)
 	if err != nil {
 		return err
 	}
-	respBody.Close()
+	defer respBody.Close()
 
+	// The pull only completes once the progress stream has been consumed.
+	if err := drainProgress(respBody); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
 	return nil
 }
 
-func (docker *dockerClient) CreateContainer(container map[string]interface{}) (string, error) {
+// startPull requests the pull of name, returning its progress stream. An empty
+// platform pulls the variant matching the daemon.
+func (docker *dockerClient) startPull(ctx context.Context, name, platform string, header http.Header) (io.ReadCloser, error) {
 	var (
-		method = "POST"
-		name   string
-		uri    = "/containers/create"
+		method    = "POST"
+		repo, tag = ParseRepositoryTag(name)
+		v         = url.Values{}
 	)
-
-	if _, exists := container["Name"]; exists {
-		uri = fmt.Sprintf("%s?name=%v", uri, name)
+	if tag == "" {
+		tag = "latest"
 	}
+	v.Set("fromImage", repo)
+	v.Set("tag", tag)
+	if platform != "" {
+		v.Set("platform", platform)
+	}
+	uri := fmt.Sprintf("/images/create?%s", v.Encode())
 
-	delete(container, "Name")
-	respBody, err := docker.newRequest(method, uri, container)
-	if err != nil {
-		// Try to see if we just need to download the image
-		if fmt.Sprintf("%v", err) == "invalid HTTP request 404 404 Not Found" {
-			if err := docker.PullImage(fmt.Sprintf("%s", container["Image"])); err != nil {
-				return "", err
-			}
-			respBody, err = docker.newRequest(method, uri, container)
-		}
-		if err != nil {
-			return "", err
-		}
+	return docker.newRawRequest(ctx, method, uri, header, nil)
+}
+
+// StopContainer stops the named container, giving it timeout seconds to exit
+// before it is killed. A negative timeout leaves the choice to the daemon.
+// Stopping a container that is not running is not an error.
+func (docker *dockerClient) StopContainer(ctx context.Context, name string, timeout int) error {
+	if err := checkName(name); err != nil {
+		return err
 	}
-	defer respBody.Close()
 
-	type createResp struct {
-		Id string
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/stop", pathName(name))
+	)
+	if timeout >= 0 {
+		uri = fmt.Sprintf("%s?t=%d", uri, timeout)
 	}
-	var respData createResp
-	err = json.NewDecoder(respBody).Decode(&respData)
+
+	// The daemon answers once the container stopped, which takes up to
+	// timeout seconds, so the client timeout does not apply.
+	respBody, err := docker.newStreamRequest(ctx, method, uri, nil)
 	if err != nil {
-		return name, err
+		if isStatus(err, http.StatusNotModified) {
+			return nil
+		}
+		return err
 	}
-	name = respData.Id
+	respBody.Close()
 
-	return name, nil
+	return nil
 }
 
-func (docker *dockerClient) StartContainer(name string, hostConfig interface{}) error {
+// RestartContainer restarts the named container, following the same timeout
+// conventions as StopContainer. The error matches ErrNotFound if there is no
+// such container.
+func (docker *dockerClient) RestartContainer(ctx context.Context, name string, timeout int) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
 	var (
 		method = "POST"
-		uri    = fmt.Sprintf("/containers/%s/start", name)
+		uri    = fmt.Sprintf("/containers/%s/restart", pathName(name))
 	)
+	if timeout >= 0 {
+		uri = fmt.Sprintf("%s?t=%d", uri, timeout)
+	}
 
-	respBody, err := docker.newRequest(method, uri, hostConfig)
+	// Not bound by the client timeout, see StopContainer.
+	respBody, err := docker.newStreamRequest(ctx, method, uri, nil)
 	if err != nil {
 		return err
 	}
-	defer respBody.Close()
+	respBody.Close()
 
 	return nil
 }
 
-func (docker *dockerClient) RunContainer(config map[string]interface{}) (string, error) {
+// KillContainer sends signal to the main process of the named container.
+// The signal may be a number ("9") or a name ("SIGTERM", "HUP"); an empty
+// signal sends SIGKILL.
+func (docker *dockerClient) KillContainer(ctx context.Context, name, signal string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	if signal == "" {
+		signal = "SIGKILL"
+	}
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/kill?signal=%s", pathName(name), url.QueryEscape(signal))
+	)
 
-	name, err := docker.CreateContainer(config)
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
 	if err != nil {
-		return "", err
+		return err
 	}
+	respBody.Close()
 
-	return name, docker.StartContainer(name, config["HostConfig"])
+	return nil
 }
 
-func (docker *dockerClient) FetchContainer(name string) (*Container, error) {
+func (docker *dockerClient) ListImages(ctx context.Context, all bool) ([]*Image, error) {
 	var (
 		method = "GET"
-		uri    = fmt.Sprintf("/containers/%s/json", name)
+		uri    = fmt.Sprintf("/images/json?all=%v", all)
 	)
 
-	respBody, err := docker.newRequest(method, uri, nil)
-
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer respBody.Close()
-	var container *Container
-	err = json.NewDecoder(respBody).Decode(&container)
-	if err != nil {
+
+	var images []*Image
+	if err = json.NewDecoder(respBody).Decode(&images); err != nil {
 		return nil, err
 	}
-	return container, nil
+	return images, nil
 }
 
-func (docker *dockerClient) FetchAllContainers(all bool) ([]*Container, error) {
+// InspectImage returns the metadata of the named image. The error matches
+// ErrNotFound if there is no such image.
+func (docker *dockerClient) InspectImage(ctx context.Context, name string) (*ImageInfo, error) {
 	var (
 		method = "GET"
-		uri    = fmt.Sprintf("/containers/json?all=%v", all)
+		uri    = fmt.Sprintf("/images/%s/json", name)
 	)
 
-	respBody, err := docker.newRequest(method, uri, nil)
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer respBody.Close()
 
-	var containers []*Container
-	if err = json.NewDecoder(respBody).Decode(&containers); err != nil {
+	var image *ImageInfo
+	if err = json.NewDecoder(respBody).Decode(&image); err != nil {
 		return nil, err
 	}
-	return containers, nil
+	return image, nil
 }
 
-func (docker *dockerClient) newRequest(method, uri string, body interface{}) (io.ReadCloser, error) {
-	bodyJson, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(method, uri, bytes.NewBuffer(bodyJson))
-	if err != nil {
-		return nil, err
+// ImageExists reports whether the named image is present locally. Only the
+// daemon not knowing the image counts as absent; any other failure, such as
+// not reaching the daemon, is returned as an error.
+func (docker *dockerClient) ImageExists(ctx context.Context, name string) (bool, error) {
+	_, err := docker.InspectImage(ctx, name)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	default:
+		return false, err
 	}
+}
 
-	req.Header.Set("Content-Type", "application/json")
+// ImageHistory lists the layers of the named image, newest first, along with
+// the instruction that created each of them.
+func (docker *dockerClient) ImageHistory(ctx context.Context, name string) ([]HistoryLayer, error) {
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/images/%s/history", name)
+	)
 
-	c, err := docker.newConn()
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer respBody.Close()
 
-	resp, err := c.Do(req)
-	if err != nil {
+	var history []HistoryLayer
+	if err = json.NewDecoder(respBody).Decode(&history); err != nil {
 		return nil, err
 	}
+	return history, nil
+}
 
-	if !docker.isOkStatus(resp.StatusCode) {
-		return nil, fmt.Errorf("invalid HTTP request %d %s", resp.StatusCode, resp.Status)
+// TagImage adds the reference repo:tag to the named image.
+func (docker *dockerClient) TagImage(ctx context.Context, name, repo, tag string) error {
+	var (
+		method = "POST"
+		v      = url.Values{}
+	)
+	v.Set("repo", repo)
+	if tag != "" {
+		v.Set("tag", tag)
 	}
+	uri := fmt.Sprintf("/images/%s/tag?%s", name, v.Encode())
 
-	r := newReadCloseWrapper(resp.Body, func() error {
-		resp.Body.Close()
-		return c.Close()
-	})
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return err
+	}
+	respBody.Close()
 
-	return r, nil
+	return nil
 }
 
-func (d *dockerClient) isOkStatus(code int) bool {
-	codes := map[int]bool{
-		200: true,
-		201: true,
-		204: true,
-		400: false,
-		404: false,
-		500: false,
-		409: false,
-		406: false,
+// PushImage pushes the named image to its registry, authenticating with auth,
+// and streams the progress of the push. If the push fails, the error is
+// delivered on the error channel before both channels are closed.
+func (docker *dockerClient) PushImage(ctx context.Context, name string, auth AuthConfig) (<-chan PushStatus, <-chan error, error) {
+	header, err := auth.header()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return codes[code]
-}
-
-func (docker *dockerClient) Info() (*DaemonInfo, error) {
 	var (
-		method = "GET"
-		uri    = "/info"
+		method    = "POST"
+		repo, tag = ParseRepositoryTag(name)
+		uri       = fmt.Sprintf("/images/%s/push", repo)
 	)
+	if tag != "" {
+		uri = fmt.Sprintf("%s?tag=%s", uri, url.QueryEscape(tag))
+	}
 
-	respBody, err := docker.newRequest(method, uri, nil)
+	respBody, err := docker.newRawRequest(ctx, method, uri, header, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer respBody.Close()
 
-	var info *DaemonInfo
-	if err = json.NewDecoder(respBody).Decode(&info); err != nil {
-		return nil, err
-	}
-	return info, nil
+	statuses, errChan := streamProgress(ctx, respBody)
+	return statuses, errChan, nil
 }
 
-func (docker *dockerClient) Version() (*DaemonVersion, error) {
+// SearchImages searches the registry for images matching term.
+func (docker *dockerClient) SearchImages(ctx context.Context, term string) ([]SearchResult, error) {
 	var (
 		method = "GET"
-		uri    = "/version"
+		uri    = fmt.Sprintf("/images/search?term=%s", url.QueryEscape(term))
 	)
 
-	respBody, err := docker.newRequest(method, uri, nil)
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer respBody.Close()
 
-	var version *DaemonVersion
-	if err = json.NewDecoder(respBody).Decode(&version); err != nil {
+	var results []SearchResult
+	if err = json.NewDecoder(respBody).Decode(&results); err != nil {
 		return nil, err
 	}
-	return version, nil
+	return results, nil
 }
 
-func (d *dockerClient) GetEvents() chan *Event {
-	eventChan := make(chan *Event, 100) // 100 event buffer
-	go func() {
-		defer close(eventChan)
-
-		respBody, err := d.newRequest("GET", "/events", nil)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		defer respBody.Close()
+func (docker *dockerClient) RemoveContainer(ctx context.Context, name string, force, volumes bool) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
 
-		// handle signals to stop the socket
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
-		go func() {
-			for sig := range sigChan {
-				log.Printf("received signal '%v', exiting", sig)
+	var (
+		method = "DELETE"
+		uri    = fmt.Sprintf("/containers/%s?force=%s&volumes=%s", pathName(name), strconv.FormatBool(force), strconv.FormatBool(volumes))
+	)
 
-				respBody.Close()
-				close(eventChan)
-				os.Exit(0)
-			}
-		}()
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return err
+	}
+	respBody.Close()
 
-		dec := json.NewDecoder(respBody)
-		for {
-			var event *Event
-			if err := dec.Decode(&event); err != nil {
-				if err == io.EOF {
-					break
-				}
-				log.Printf("cannot decode json: %s", err)
-				continue
-			}
-			eventChan <- event
-		}
-	}()
-	return eventChan
+	return nil
 }
 
-func (d *dockerClient) ContainerLogs(id string, follow, stdout, stderr, timestamps bool, tail int) (io.ReadCloser, error) {
-	tailStr := strconv.Itoa(tail)
-	if tail == -1 {
-		tailStr = "all"
+// CreateContainer creates a container from the raw create request in
+// container. The "Name" and "Platform" keys are taken out of it and sent as
+// query parameters, see CreateContainerWithConfig.
+func (docker *dockerClient) CreateContainer(ctx context.Context, container map[string]interface{}) (string, error) {
+	var name, platform string
+	if n, exists := container["Name"]; exists {
+		name = fmt.Sprintf("%v", n)
+	}
+	if p, exists := container["Platform"]; exists {
+		platform = fmt.Sprintf("%v", p)
 	}
-	uri := fmt.Sprintf("/containers/%s/logs?follow=%v&stdout=%v&stderr=%v&timestamps=%v&tail=%v", id, follow, stdout, stderr, timestamps, tailStr)
 
-	respBody, err := d.newRequest("GET", uri, nil)
-	if err != nil {
-		return nil, err
+	delete(container, "Name")
+	delete(container, "Platform")
+	return docker.createContainer(ctx, name, platform, fmt.Sprintf("%v", container["Image"]), container)
+}
+
+// CreateContainerWithConfig is the typed counterpart of CreateContainer. An
+// empty name lets the daemon generate one.
+func (docker *dockerClient) CreateContainerWithConfig(ctx context.Context, cfg ContainerConfig, host HostConfig, name string) (string, error) {
+	if err := cfg.validate(); err != nil {
+		return "", err
+	}
+	if err := host.validate(); err != nil {
+		return "", err
+	}
+	// Catch a typo in the network container before anything is created.
+	if other := host.networkContainer(); other != "" {
+		if _, err := docker.FetchContainer(ctx, other); err != nil {
+			return "", fmt.Errorf("network mode %q: %w", host.NetworkMode, err)
+		}
+	}
+	if err := applyPorts(&cfg, &host); err != nil {
+		return "", err
 	}
 
-	return respBody, nil
+	body := struct {
+		ContainerConfig
+		HostConfig HostConfig
+	}{cfg, host}
+
+	return docker.createContainer(ctx, name, cfg.Platform, cfg.Image, body)
 }
 
-func (d *dockerClient) Copy(id string, file string) (io.ReadCloser, error) {
+func (docker *dockerClient) createContainer(ctx context.Context, name, platform, image string, body interface{}) (string, error) {
 	var (
 		method = "POST"
-		uri    = fmt.Sprintf("/containers/%s/copy", id)
-		body   = map[string]string{"Resource": file}
+		uri    = "/containers/create"
+		v      = url.Values{}
 	)
 
-	respBody, err := d.newRequest(method, uri, body)
+	if name != "" {
+		if err := checkName(name); err != nil {
+			return "", err
+		}
+		v.Set("name", name)
+	}
+	if platform != "" {
+		v.Set("platform", platform)
+	}
+	if len(v) > 0 {
+		uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+	}
+
+	respBody, err := docker.newRequest(ctx, method, uri, body)
 	if err != nil {
-		return nil, err
+		// Try to see if we just need to download the image
+		if errors.Is(err, ErrNotFound) {
+			if err := docker.pullImage(ctx, image, platform, nil); err != nil {
+				return "", err
+			}
+			respBody, err = docker.newRequest(ctx, method, uri, body)
+		}
+		if err != nil {
+			return "", err
+		}
 	}
+	defer respBody.Close()
 
-	return respBody, nil
+	type createResp struct {
+		Id string
+	}
+	var respData createResp
+	err = json.NewDecoder(respBody).Decode(&respData)
+	if err != nil {
+		return "", err
+	}
+
+	return respData.Id, nil
 }
 
-func (d *dockerClient) ContainerPause(id string) error {
-	var (
-		method = "POST"
-		uri    = fmt.Sprintf("/containers/%s/pause", id)
-	)
-	respBody, err := d.newRequest(method, uri, nil)
-	if err != nil {
+// StartContainer starts the named container. The host config should be set
+// when the container is created and hostConfig left nil: only old daemons
+// accept it here and newer ones reject a start request with a body. Starting
+// a running container fails with an error matching ErrAlreadyStarted.
+func (docker *dockerClient) StartContainer(ctx context.Context, name string, hostConfig interface{}) error {
+	if err := checkName(name); err != nil {
 		return err
 	}
-	respBody.Close()
-	return nil
-}
 
-func (d *dockerClient) ContainerUnpause(id string) error {
 	var (
 		method = "POST"
-		uri    = fmt.Sprintf("/containers/%s/unpause", id)
+		uri    = fmt.Sprintf("/containers/%s/start", pathName(name))
 	)
-	respBody, err := d.newRequest(method, uri, nil)
+
+	respBody, err := docker.newRequest(ctx, method, uri, hostConfig)
 	if err != nil {
+		if isStatus(err, http.StatusNotModified) {
+			return fmt.Errorf("%w: %s", ErrAlreadyStarted, name)
+		}
 		return err
 	}
-	respBody.Close()
+	defer respBody.Close()
 
 	return nil
 }
 
-func (d *dockerClient) Build(ctx io.Reader, tag string, nocache bool, forcerm bool) (io.ReadCloser, error) {
-	var (
-		method = "POST"
-		uri    = "/build"
-	)
+func (docker *dockerClient) RunContainer(ctx context.Context, config map[string]interface{}) (string, error) {
 
-	v := &url.Values{}
-	if tag != "" {
-		v.Set("t", tag)
+	name, err := docker.CreateContainer(ctx, config)
+	if err != nil {
+		return "", err
+	}
+
+	// HostConfig was already sent along with the create request.
+	return name, docker.StartContainer(ctx, name, nil)
+}
+
+// RunContainerWithConfig is the typed counterpart of RunContainer.
+func (docker *dockerClient) RunContainerWithConfig(ctx context.Context, cfg ContainerConfig, host HostConfig, name string) (string, error) {
+	id, err := docker.CreateContainerWithConfig(ctx, cfg, host, name)
+	if err != nil {
+		return "", err
+	}
+
+	return id, docker.StartContainer(ctx, id, nil)
+}
+
+// RunAndWait creates and starts a container, waits up to timeout for it to
+// exit and returns its exit code. A timeout of zero or less waits for as long
+// as ctx allows; if the container is still running once timeout expires, the
+// error matches ErrStillRunning. The container is force removed if anything
+// goes wrong along the way, and once it exited if remove is set. Containers
+// with host.AutoRemove set are left for the daemon to remove.
+func (docker *dockerClient) RunAndWait(ctx context.Context, cfg ContainerConfig, host HostConfig, timeout time.Duration, remove bool) (code int, err error) {
+	id, err := docker.CreateContainerWithConfig(ctx, cfg, host, "")
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		if err == nil && (!remove || host.AutoRemove) {
+			return
+		}
+		// ctx may be what made us fail, the container must go anyway.
+		rmErr := docker.RemoveContainer(context.WithoutCancel(ctx), id, true, true)
+		if err == nil && rmErr != nil && !errors.Is(rmErr, ErrNotFound) {
+			err = rmErr
+		}
+	}()
+
+	waitCtx, cancel := withWaitTimeout(ctx, timeout)
+	defer cancel()
+	// Only a container which started and outlived the wait is still running,
+	// a request running into the client timeout is reported as such.
+	var started bool
+	defer func() {
+		if started && err != nil && waitCtx.Err() != nil && ctx.Err() == nil {
+			err = fmt.Errorf("%w: %s", ErrStillRunning, id)
+		}
+	}()
+
+	// An auto removed container may be gone before a wait sent after the
+	// start would find it, so wait for the next exit before starting it.
+	var waitBody io.ReadCloser
+	if host.AutoRemove {
+		if waitBody, err = docker.startWait(waitCtx, id, "next-exit"); err != nil {
+			return -1, err
+		}
+		defer waitBody.Close()
+	}
+
+	if err := docker.StartContainer(ctx, id, nil); err != nil {
+		return -1, err
+	}
+	started = true
+
+	if waitBody == nil {
+		if waitBody, err = docker.startWait(waitCtx, id, ""); err != nil {
+			return -1, err
+		}
+		defer waitBody.Close()
+	}
+	return decodeWait(waitCtx, waitBody)
+}
+
+// FetchContainer inspects the container with the given name or ID. A leading
+// slash in the name is ignored and, if the daemon does not know the name, it
+// is matched against the names and ID prefixes of all containers.
+func (docker *dockerClient) FetchContainer(ctx context.Context, name string) (*Container, error) {
+	return docker.InspectContainer(ctx, name, false)
+}
+
+// InspectContainer is like FetchContainer but, if size is set, also has the
+// daemon compute the size of the container's filesystem into SizeRw and
+// SizeRootFs. This can take a while for large containers.
+func (docker *dockerClient) InspectContainer(ctx context.Context, name string, size bool) (*Container, error) {
+	name = strings.TrimPrefix(name, "/")
+
+	container, err := docker.inspectContainer(ctx, name, size)
+	if !errors.Is(err, ErrNotFound) || name == "" {
+		return container, err
+	}
+
+	id, resolveErr := docker.resolveContainer(ctx, name)
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	if id == "" {
+		return nil, err
+	}
+	return docker.inspectContainer(ctx, id, size)
+}
+
+func (docker *dockerClient) inspectContainer(ctx context.Context, name string, size bool) (*Container, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/containers/%s/json", pathName(name))
+	)
+	if size {
+		uri += "?size=true"
+	}
+
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+	var container *Container
+	err = json.NewDecoder(respBody).Decode(&container)
+	if err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+// ContainerPort returns the host addresses the given port of the named
+// container is published on, including ports picked by the daemon. The port
+// may be given as "80" for tcp or with a protocol, e.g. "53/udp".
+func (docker *dockerClient) ContainerPort(ctx context.Context, name, containerPort string) ([]Binding, error) {
+	port, proto, found := strings.Cut(containerPort, "/")
+	if !found {
+		proto = "tcp"
+	}
+	if !isPort(port) || proto == "" {
+		return nil, fmt.Errorf("invalid container port %q", containerPort)
+	}
+	key := port + "/" + proto
+
+	container, err := docker.FetchContainer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var bindings []Binding
+	if container.NetworkSettings != nil {
+		bindings = container.NetworkSettings.Ports[key]
+	}
+	if len(bindings) == 0 {
+		return nil, fmt.Errorf("port %s of container %s is not published", key, name)
+	}
+	return bindings, nil
+}
+
+// resolveContainer returns the ID of the only container named name or whose
+// ID starts with name, or an empty ID if there is none.
+func (docker *dockerClient) resolveContainer(ctx context.Context, name string) (string, error) {
+	containers, err := docker.ListContainers(ctx, ListOptions{All: true})
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, c := range containers {
+		for _, n := range c.Names {
+			if strings.TrimPrefix(n, "/") == name {
+				return c.Id, nil
+			}
+		}
+		if strings.HasPrefix(c.Id, name) {
+			matches = append(matches, c.Id)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%q matches more than one container", name)
+	}
+}
+
+// pollInterval is how often the Wait* helpers inspect a container.
+const pollInterval = 500 * time.Millisecond
+
+// withWaitTimeout bounds ctx by the timeout of a Wait* helper, where zero or
+// less means no limit.
+func withWaitTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// WaitHealthy polls the named container until its health check reports it
+// healthy or timeout expires, where zero or less waits for as long as ctx
+// allows. It fails early if the container stops, turns unhealthy or has no
+// health check.
+func (docker *dockerClient) WaitHealthy(ctx context.Context, name string, timeout time.Duration) error {
+	ctx, cancel := withWaitTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		container, err := docker.FetchContainer(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case container.State.Health == nil:
+			return fmt.Errorf("container %s has no health check", name)
+		case container.State.Health.Status == "healthy":
+			return nil
+		case container.State.Health.Status == "unhealthy":
+			return fmt.Errorf("container %s is unhealthy", name)
+		case !container.State.Running:
+			return fmt.Errorf("%w: %s", ErrNotRunning, name)
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// WaitRunning polls the named container until it is running and, unless it
+// shares the host's or another container's network or has none, has an IP
+// address. It returns the container as last inspected and fails early if the
+// container exits. A timeout of zero or less waits for as long as ctx allows.
+func (docker *dockerClient) WaitRunning(ctx context.Context, name string, timeout time.Duration) (*Container, error) {
+	ctx, cancel := withWaitTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		container, err := docker.FetchContainer(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		switch container.State.Status {
+		case "exited", "dead":
+			return nil, fmt.Errorf("%w: %s", ErrNotRunning, name)
+		}
+		if container.State.Running && container.hasAddress() {
+			return container, nil
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (docker *dockerClient) FetchAllContainers(ctx context.Context, all bool) ([]*ContainerSummary, error) {
+	return docker.ListContainers(ctx, ListOptions{All: all})
+}
+
+// ListContainers lists the containers matching opts.
+func (docker *dockerClient) ListContainers(ctx context.Context, opts ListOptions) ([]*ContainerSummary, error) {
+	v, err := opts.values()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/containers/json?%s", v.Encode())
+	)
+
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+
+	var containers []*ContainerSummary
+	if err = json.NewDecoder(respBody).Decode(&containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// newRequest is like newStreamRequest but bounded by the client's timeout, if
+// any, until the returned body is closed.
+func (docker *dockerClient) newRequest(ctx context.Context, method, uri string, body interface{}) (io.ReadCloser, error) {
+	if docker.timeout <= 0 {
+		return docker.newStreamRequest(ctx, method, uri, body)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, docker.timeout)
+	respBody, err := docker.newStreamRequest(ctx, method, uri, body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return newReadCloseWrapper(respBody, func() error {
+		defer cancel()
+		return respBody.Close()
+	}), nil
+}
+
+// newStreamRequest sends body encoded as JSON. A nil body sends an empty
+// request body, which some endpoints like /start require on newer daemons.
+func (docker *dockerClient) newStreamRequest(ctx context.Context, method, uri string, body interface{}) (io.ReadCloser, error) {
+	if body == nil {
+		return docker.newRawRequest(ctx, method, uri, nil, nil)
+	}
+
+	bodyJson, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	return docker.newRawRequest(ctx, method, uri, header, bytes.NewBuffer(bodyJson))
+}
+
+// newRawRequest sends body as-is along with header. Cancelling ctx aborts the
+// request, including reads from the returned body.
+func (docker *dockerClient) newRawRequest(ctx context.Context, method, uri string, header http.Header, body io.Reader) (io.ReadCloser, error) {
+	resp, err := docker.sendRequest(ctx, method, uri, header, body)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Do sends a request to an endpoint of the API which has no method of its
+// own, such as one added by a newer daemon. path is relative to the API
+// version, e.g. "/containers/json?all=1". body is sent as-is if it is an
+// io.Reader and encoded as JSON otherwise. The request goes through the same
+// connections, retries and error handling as the other methods: a status
+// other than a success is returned as an *APIError. Since the response may be
+// a stream, the client timeout does not apply; bound the request with ctx
+// instead. The caller must close the response body.
+//
+// Do is meant as an escape hatch; prefer the typed methods where they exist.
+func (docker *dockerClient) Do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid path %q: must start with /", path)
+	}
+
+	var (
+		header  http.Header
+		reqBody io.Reader
+	)
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		reqBody = body
+	default:
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		header = http.Header{"Content-Type": {"application/json"}}
+		reqBody = bytes.NewReader(buf)
+	}
+
+	return docker.sendRequest(ctx, method, path, header, reqBody)
+}
+
+// sendRequest sends a request and checks the status of its response.
+func (docker *dockerClient) sendRequest(ctx context.Context, method, uri string, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, docker.url(uri), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := docker.do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	if !docker.isOkStatus(resp.StatusCode) {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+
+	return resp, nil
+}
+
+// do sends req, retrying it as allowed by the client's retry policy.
+func (docker *dockerClient) do(req *http.Request) (*http.Response, error) {
+	retry := docker.getRetryPolicy()
+	for attempt := 1; ; attempt++ {
+		resp, err := docker.client.Do(req)
+		if attempt >= retry.MaxAttempts || !retry.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(retry.delay(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// url turns a request URI into the absolute URL the http client expects.
+func (docker *dockerClient) url(uri string) string {
+	host := "docker"
+	if proto, addr := ParseURL(docker.path); proto == "tcp" {
+		host = addr
+	}
+	if docker.version != "" {
+		uri = "/" + docker.version + uri
+	}
+	return "http://" + host + uri
+}
+
+func (d *dockerClient) isOkStatus(code int) bool {
+	// 101 is the attach connection being upgraded. Endpoints answering 304
+	// when there is nothing to do, like starting a running container, deal
+	// with it themselves from the APIError.
+	return code == http.StatusSwitchingProtocols || (code >= 200 && code < 300)
+}
+
+func (docker *dockerClient) Info(ctx context.Context) (*DaemonInfo, error) {
+	var (
+		method = "GET"
+		uri    = "/info"
+	)
+
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+
+	var info *DaemonInfo
+	if err = json.NewDecoder(respBody).Decode(&info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Ping checks that the daemon is reachable and answering requests.
+func (docker *dockerClient) Ping(ctx context.Context) error {
+	var (
+		method = "GET"
+		uri    = "/_ping"
+	)
+
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return err
+	}
+	respBody.Close()
+
+	return nil
+}
+
+// Version reports the versions of the daemon and of the API it speaks by
+// default, which tells which request fields are safe to send.
+func (docker *dockerClient) Version(ctx context.Context) (*DaemonVersion, error) {
+	var (
+		method = "GET"
+		uri    = "/version"
+	)
+
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+
+	var version *DaemonVersion
+	if err = json.NewDecoder(respBody).Decode(&version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// DiskUsage reports the disk space used by images, containers, volumes and
+// the build cache. Computing it can take a while on a busy daemon.
+func (docker *dockerClient) DiskUsage(ctx context.Context) (*DiskUsageReport, error) {
+	var (
+		method = "GET"
+		uri    = "/system/df"
+	)
+
+	respBody, err := docker.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+
+	var resp diskUsageResponse
+	if err := json.NewDecoder(respBody).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return resp.report(), nil
+}
+
+// GetEvents streams events from the daemon until the connection is closed or
+// ctx is cancelled, at which point both channels are closed. If the stream
+// fails before that, the error is delivered on the error channel first.
+func (d *dockerClient) GetEvents(ctx context.Context) (<-chan *Event, <-chan error, error) {
+	return d.GetEventsFiltered(ctx, EventOptions{})
+}
+
+// GetContainerEvents is like GetEvents but leaves out the events of images,
+// volumes, networks and other objects, so that ContainerId and Status are
+// always set.
+func (d *dockerClient) GetContainerEvents(ctx context.Context) (<-chan *Event, <-chan error, error) {
+	return d.GetEventsFiltered(ctx, EventOptions{
+		Filters: map[string][]string{"type": {"container"}},
+	})
+}
+
+// GetEventsFiltered is like GetEvents but only streams the events matching
+// opts.
+func (d *dockerClient) GetEventsFiltered(ctx context.Context, opts EventOptions) (<-chan *Event, <-chan error, error) {
+	eventChan, errChan, _, err := d.streamEvents(ctx, opts)
+	return eventChan, errChan, err
+}
+
+// streamEvents is GetEventsFiltered, also returning when the stream started
+// by the daemon's clock, to the second, or the zero time if it did not say.
+func (d *dockerClient) streamEvents(ctx context.Context, opts EventOptions) (<-chan *Event, <-chan error, time.Time, error) {
+	v, err := opts.values()
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	uri := "/events"
+	if len(v) > 0 {
+		uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+	}
+
+	resp, err := d.sendRequest(ctx, "GET", uri, nil, nil)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	started, _ := http.ParseTime(resp.Header.Get("Date"))
+
+	respBody := resp.Body
+	if opts.IdleTimeout > 0 {
+		respBody = newIdleReader(respBody, opts.IdleTimeout)
+	}
+
+	var (
+		eventChan = make(chan *Event, opts.bufferSize())
+		errChan   = make(chan error, 1)
+	)
+	go func() {
+		// This goroutine is the only sender on both channels, so it is the
+		// only one allowed to close them.
+		defer close(errChan)
+		defer close(eventChan)
+		defer respBody.Close()
+
+		err := readEvents(respBody, func(event *Event) bool {
+			if opts.DropWhenFull {
+				select {
+				case eventChan <- event:
+				default:
+					if opts.Dropped != nil {
+						opts.Dropped.Add(1)
+					}
+				}
+				return true
+			}
+			select {
+			case eventChan <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			errChan <- err
+		}
+	}()
+	return eventChan, errChan, started, nil
+}
+
+// GetEventsReconnect is like GetEvents but keeps the stream going across
+// dropped connections, e.g. when the daemon restarts, by re-dialing with an
+// exponential backoff. Events that happened while disconnected are replayed
+// using the time of the last event seen. A connection which stays silent for
+// several minutes is assumed dead and replaced as well.
+//
+// The errors that caused a reconnect are delivered on the error channel as
+// long as it has room for them, so a consumer that falls behind misses some
+// but never holds up the events. If the daemon rejects the request, which
+// reconnecting won't fix, that error is delivered and both channels are
+// closed. Otherwise they are only closed once ctx is cancelled or the client
+// is closed.
+func (d *dockerClient) GetEventsReconnect(ctx context.Context) (<-chan *Event, <-chan error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(d.closed, cancel)
+
+	var (
+		eventChan = make(chan *Event, 100)
+		errChan   = make(chan error, 10)
+	)
+	go func() {
+		defer close(errChan)
+		defer close(eventChan)
+		defer stop()
+		defer cancel()
+
+		var (
+			opts  = EventOptions{IdleTimeout: reconnectIdleTimeout}
+			delay = minReconnectDelay
+		)
+		for {
+			events, streamErrs, started, err := d.streamEvents(ctx, opts)
+			if err == nil {
+				// Replay from when the first stream started by the daemon's
+				// clock, ours may be off.
+				if opts.Since.IsZero() {
+					opts.Since = started
+				}
+				if opts.Since.IsZero() {
+					opts.Since = time.Now()
+				}
+				delay = minReconnectDelay
+
+				for event := range events {
+					select {
+					case eventChan <- event:
+					case <-ctx.Done():
+						return
+					}
+					// since is inclusive, skip past the event we already have.
+					opts.Since = event.timestamp().Add(time.Nanosecond)
+				}
+				err = <-streamErrs
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				var apiErr *APIError
+				if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+					select {
+					case errChan <- err:
+					case <-ctx.Done():
+					}
+					return
+				}
+				select {
+				case errChan <- err:
+				default:
+				}
+			}
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+		}
+	}()
+	return eventChan, errChan
+}
+
+// ContainerLogs returns the raw log stream of the container. A tail of -1
+// returns all lines; since and until are Unix timestamps bounding the logs and
+// are ignored when zero. Cancelling ctx closes the connection of this stream
+// only, so several followers can be stopped independently.
+func (d *dockerClient) ContainerLogs(ctx context.Context, id string, follow, stdout, stderr, timestamps bool, tail int, since, until int64) (io.ReadCloser, error) {
+	opts := newLogOptions(follow, stdout, stderr, timestamps, tail, since, until)
+	return d.ContainerLogsRaw(ctx, id, opts)
+}
+
+// followRetryTimeout is how long a follow of the logs of a container waits for
+// the container to be created.
+const followRetryTimeout = 5 * time.Second
+
+// ContainerLogsRaw returns the undecoded log stream of the container, which
+// is multiplexed for containers without a TTY unless opts.Demux is set.
+// Without opts.Follow the stream holds the whole log selected by opts and
+// ends once it was read. With it, a container that does not exist yet is
+// waited for briefly, so that the logs of one being created concurrently can
+// be followed.
+func (d *dockerClient) ContainerLogsRaw(ctx context.Context, id string, opts LogOptions) (io.ReadCloser, error) {
+	if err := checkName(id); err != nil {
+		return nil, err
+	}
+
+	uri := fmt.Sprintf("/containers/%s/logs?%s", pathName(id), opts.values().Encode())
+
+	deadline := time.Now().Add(followRetryTimeout)
+	for {
+		respBody, err := d.newStreamRequest(ctx, "GET", uri, nil)
+		if err == nil {
+			if opts.IdleTimeout > 0 {
+				respBody = newIdleReader(respBody, opts.IdleTimeout)
+			}
+			if opts.Demux {
+				return newReadCloseWrapper(newDemuxReader(respBody), respBody.Close), nil
+			}
+			return respBody, nil
+		}
+		if !opts.Follow || !errors.Is(err, ErrNotFound) || time.Now().After(deadline) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// ContainerLogsAll returns the current log of the container in one go, with
+// stdout and stderr merged. A tail of -1 returns all lines.
+func (d *dockerClient) ContainerLogsAll(ctx context.Context, id string, stdout, stderr, timestamps bool, tail int) (string, error) {
+	opts := newLogOptions(false, stdout, stderr, timestamps, tail, 0, 0)
+	opts.Demux = true
+
+	respBody, err := d.ContainerLogsRaw(ctx, id, opts)
+	if err != nil {
+		return "", err
+	}
+	defer respBody.Close()
+
+	buf, err := io.ReadAll(respBody)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// ContainerLogLines is like ContainerLogs but decodes the stream into lines,
+// separating stdout from stderr for containers started without a TTY. Both
+// channels are closed when the stream ends or ctx is cancelled; if reading the
+// stream fails, the error is delivered on the error channel first. The error
+// channel closing without an error thus means every line was delivered, which
+// for a stopped container or without follow is its whole log.
+func (d *dockerClient) ContainerLogLines(ctx context.Context, id string, follow, stdout, stderr, timestamps bool, tail int, since, until int64) (<-chan *LogLine, <-chan error, error) {
+	opts := newLogOptions(follow, stdout, stderr, timestamps, tail, since, until)
+	return d.ContainerLogsOpts(ctx, id, opts)
+}
+
+// ContainerLogsOpts is ContainerLogLines with the logs selected by opts.
+// opts.Demux is ignored since lines are always told apart by stream.
+func (d *dockerClient) ContainerLogsOpts(ctx context.Context, id string, opts LogOptions) (<-chan *LogLine, <-chan error, error) {
+	opts.Demux = false
+	respBody, err := d.ContainerLogsRaw(ctx, id, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		lines   = make(chan *LogLine, 100)
+		errChan = make(chan error, 1)
+	)
+	go func() {
+		// Sole sender and closer of both channels, see GetEvents.
+		defer close(errChan)
+		defer close(lines)
+		defer respBody.Close()
+
+		err := readLogLines(respBody, func(l *LogLine) bool {
+			if opts.SplitTimestamps {
+				l.Time, l.Line = splitTimestamp(l.Line)
+			}
+			select {
+			case lines <- l:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			errChan <- err
+		}
+	}()
+	return lines, errChan, nil
+}
+
+// Deprecated: use CopyFromContainer.
+func (d *dockerClient) Copy(ctx context.Context, id string, file string) (io.ReadCloser, error) {
+	if err := checkName(id); err != nil {
+		return nil, err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/copy", pathName(id))
+		body   = map[string]string{"Resource": file}
+	)
+
+	respBody, err := d.newStreamRequest(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return respBody, nil
+}
+
+// CopyToContainer extracts the tar archive read from content into the
+// directory path in the container. Unless noOverwriteDirNonDir is set, a
+// directory may replace a file and vice versa.
+func (d *dockerClient) CopyToContainer(ctx context.Context, id, path string, content io.Reader, noOverwriteDirNonDir bool) error {
+	if err := checkName(id); err != nil {
+		return err
+	}
+
+	var (
+		method = "PUT"
+		uri    = fmt.Sprintf("/containers/%s/archive", pathName(id))
+		v      = url.Values{}
+		header = http.Header{"Content-Type": {"application/x-tar"}}
+	)
+	v.Set("path", path)
+	if noOverwriteDirNonDir {
+		v.Set("noOverwriteDirNonDir", "1")
+	}
+	uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+
+	respBody, err := d.newRawRequest(ctx, method, uri, header, content)
+	if err != nil {
+		return err
+	}
+	respBody.Close()
+
+	return nil
+}
+
+// CopyFromContainer returns a tar archive of path in the container.
+func (d *dockerClient) CopyFromContainer(ctx context.Context, id, path string) (io.ReadCloser, error) {
+	if err := checkName(id); err != nil {
+		return nil, err
+	}
+
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/containers/%s/archive?path=%s", pathName(id), url.QueryEscape(path))
+	)
+
+	respBody, err := d.newStreamRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return respBody, nil
+}
+
+// PauseContainer freezes all processes in the named container. The error
+// matches ErrAlreadyPaused if the container is already paused and
+// ErrNotRunning if it is not running. Both also match ErrConflict.
+func (d *dockerClient) PauseContainer(ctx context.Context, name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/pause", pathName(name))
+	)
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+			if strings.Contains(apiErr.Message, "already paused") {
+				return fmt.Errorf("%w: %w", ErrAlreadyPaused, err)
+			}
+			return fmt.Errorf("%w: %w", ErrNotRunning, err)
+		}
+		return err
+	}
+	respBody.Close()
+	return nil
+}
+
+// UnpauseContainer resumes a container paused with PauseContainer. The error
+// matches both ErrNotPaused and ErrConflict if the container is not paused.
+func (d *dockerClient) UnpauseContainer(ctx context.Context, name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/unpause", pathName(name))
+	)
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		if isStatus(err, http.StatusConflict) {
+			return fmt.Errorf("%w: %w", ErrNotPaused, err)
+		}
+		return err
+	}
+	respBody.Close()
+
+	return nil
+}
+
+// Deprecated: use PauseContainer.
+func (d *dockerClient) ContainerPause(ctx context.Context, id string) error {
+	return d.PauseContainer(ctx, id)
+}
+
+// Deprecated: use UnpauseContainer.
+func (d *dockerClient) ContainerUnpause(ctx context.Context, id string) error {
+	return d.UnpauseContainer(ctx, id)
+}
+
+// BuildImage builds an image from the tar archive read from buildContext and
+// streams the build output line by line. If the build fails, the error is
+// delivered on the error channel before both channels are closed.
+func (d *dockerClient) BuildImage(ctx context.Context, buildContext io.Reader, opts BuildOptions) (<-chan string, <-chan error, error) {
+	v, err := opts.values()
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/build?%s", v.Encode())
+		header = http.Header{"Content-Type": {"application/x-tar"}}
+	)
+
+	respBody, err := d.newRawRequest(ctx, method, uri, header, buildContext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		lines   = make(chan string, 100)
+		errChan = make(chan error, 1)
+	)
+	go func() {
+		defer close(errChan)
+		defer close(lines)
+		defer respBody.Close()
+
+		dec := json.NewDecoder(respBody)
+		for {
+			var m progressMessage
+			if err := dec.Decode(&m); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					errChan <- err
+				}
+				return
+			}
+			if m.Error != "" {
+				errChan <- errors.New(m.Error)
+				return
+			}
+			if m.Stream == "" {
+				continue
+			}
+			select {
+			case lines <- trimNewline(m.Stream):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lines, errChan, nil
+}
+
+func (d *dockerClient) Build(ctx context.Context, buildContext io.Reader, tag string, nocache bool, forcerm bool) (io.ReadCloser, error) {
+	var (
+		method = "POST"
+		uri    = "/build"
+	)
+
+	v := &url.Values{}
+	if tag != "" {
+		v.Set("t", tag)
+	}
+	if nocache {
+		v.Set("nocache", "1")
+	}
+	v.Set("rm", "1")
+	if forcerm {
+		v.Set("forcerm", "1")
+	}
+
+	uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+	header := http.Header{"Content-Type": {"application/tar"}}
+	return d.newRawRequest(ctx, method, uri, header, buildContext)
+}
+
+func (d *dockerClient) DecodeStream(stream io.Reader) []string {
+	type msg struct {
+		Stream string `json:"stream"`
+	}
+	var msgs []string
+	dec := json.NewDecoder(stream)
+	for {
+		var m msg
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				break
+			}
+			continue
+		}
+		msgs = append(msgs, strings.TrimSuffix(m.Stream, "\n"))
+	}
+	return msgs
+}
+
+// RemoveImage removes the named image and returns what the daemon untagged and
+// deleted as a result.
+func (d *dockerClient) RemoveImage(ctx context.Context, name string, force bool, noprune bool) ([]ImageDeleteResponse, error) {
+	var (
+		method = "DELETE"
+		uri    = fmt.Sprintf("/images/%s", name)
+		v      = &url.Values{}
+	)
+	if force {
+		v.Set("force", "1")
+	}
+	if noprune {
+		v.Set("noprune", "1")
+	}
+	uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+
+	var deleted []ImageDeleteResponse
+	if err = json.NewDecoder(respBody).Decode(&deleted); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// WaitContainer blocks until the named container exits and returns its exit
+// code. The wait is bounded only by ctx. Containers created with AutoRemove
+// may be gone by the time they are waited for, in which case ErrNotFound is
+// returned; RunAndWait avoids that by waiting before the container starts.
+func (d *dockerClient) WaitContainer(ctx context.Context, name string) (int, error) {
+	respBody, err := d.startWait(ctx, name, "")
+	if err != nil {
+		return -1, err
+	}
+	defer respBody.Close()
+
+	return decodeWait(ctx, respBody)
+}
+
+// WaitForExit waits up to timeout for the named container to exit and returns
+// its exit code. A container that already exited returns right away, one that
+// was created but not started yet is waited on until it ran. A timeout of zero
+// or less waits for as long as ctx allows; if the container has not exited
+// once timeout expires, the error matches ErrStillRunning.
+func (d *dockerClient) WaitForExit(ctx context.Context, name string, timeout time.Duration) (code int, err error) {
+	waitCtx, cancel := withWaitTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		// Only the timeout of the wait means the container is still
+		// running, not the client timeout of one of its requests.
+		if err != nil && waitCtx.Err() != nil && ctx.Err() == nil {
+			err = fmt.Errorf("%w: %s", ErrStillRunning, name)
+		}
+	}()
+
+	for {
+		container, err := d.FetchContainer(waitCtx, name)
+		if err != nil {
+			return -1, err
+		}
+
+		switch container.State.Status {
+		case "exited", "dead":
+			return container.State.ExitCode, nil
+		case "created":
+			// The daemon's wait returns right away for a container that is
+			// not running, so poll until it was started.
+			select {
+			case <-time.After(pollInterval):
+				continue
+			case <-waitCtx.Done():
+				return -1, waitCtx.Err()
+			}
+		}
+
+		return d.WaitContainer(waitCtx, container.Id)
+	}
+}
+
+// startWait sends a wait request for the named container, returning once the
+// daemon acknowledged it. condition is one of "not-running", "next-exit" and
+// "removed", or empty for the daemon's default.
+func (d *dockerClient) startWait(ctx context.Context, name, condition string) (io.ReadCloser, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/wait", pathName(name))
+	)
+	if condition != "" {
+		uri += "?condition=" + url.QueryEscape(condition)
+	}
+
+	return d.newStreamRequest(ctx, method, uri, nil)
+}
+
+// decodeWait reads the exit code from the response to a wait request.
+func decodeWait(ctx context.Context, body io.Reader) (int, error) {
+	var resp struct {
+		StatusCode int
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return -1, err
+	}
+	return resp.StatusCode, nil
+}
+
+// Deprecated: use WaitContainer.
+func (d *dockerClient) ContainerWait(ctx context.Context, name string) error {
+	_, err := d.WaitContainer(ctx, name)
+	return err
+}
+
+// AttachContainer hijacks a connection to the container's stdio. Writes go to
+// the container's stdin if opts.Stdin is set and reads return its output,
+// which is multiplexed for containers without a TTY unless opts.Demux is set.
+func (d *dockerClient) AttachContainer(ctx context.Context, id string, opts AttachOptions) (io.ReadWriteCloser, error) {
+	if err := checkName(id); err != nil {
+		return nil, err
+	}
+
+	var (
+		method = "POST"
+		v      = opts.values()
+		uri    = fmt.Sprintf("/containers/%s/attach?%s", pathName(id), v.Encode())
+		header = http.Header{"Connection": {"Upgrade"}, "Upgrade": {"tcp"}}
+	)
+
+	respBody, err := d.newRawRequest(ctx, method, uri, header, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, ok := respBody.(io.ReadWriteCloser)
+	if !ok {
+		respBody.Close()
+		return nil, fmt.Errorf("daemon did not upgrade the attach connection")
+	}
+	if opts.Demux {
+		return &readWriteCloser{Reader: newDemuxReader(conn), WriteCloser: conn}, nil
+	}
+	return conn, nil
+}
+
+// ContainerStats streams resource usage samples of the named container until
+// ctx is cancelled. If stream is false a single sample is sent. Both channels
+// are closed once no more samples are coming; if reading the stream fails,
+// the error is delivered on the error channel first.
+func (d *dockerClient) ContainerStats(ctx context.Context, name string, stream bool) (<-chan *Stats, <-chan error, error) {
+	if err := checkName(name); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/containers/%s/stats?stream=%v", pathName(name), stream)
+	)
+
+	respBody, err := d.newStreamRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		statsChan = make(chan *Stats)
+		errChan   = make(chan error, 1)
+	)
+	go func() {
+		// Sole sender and closer of both channels, see GetEvents.
+		defer close(errChan)
+		defer close(statsChan)
+		defer respBody.Close()
+
+		dec := json.NewDecoder(respBody)
+		for {
+			var stats *Stats
+			if err := dec.Decode(&stats); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					errChan <- err
+				}
+				return
+			}
+			select {
+			case statsChan <- stats:
+			case <-ctx.Done():
+				return
+			}
+			if !stream {
+				return
+			}
+		}
+	}()
+	return statsChan, errChan, nil
+}
+
+// ExecCreate sets up cmd to be run in the container and returns the ID of the
+// exec instance, which is then run with ExecStart.
+func (d *dockerClient) ExecCreate(ctx context.Context, container string, cmd []string, opts ExecConfig) (string, error) {
+	if err := checkName(container); err != nil {
+		return "", err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/exec", pathName(container))
+		body   = struct {
+			ExecConfig
+			Cmd []string
+		}{opts, cmd}
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, body)
+	if err != nil {
+		return "", err
+	}
+	defer respBody.Close()
+
+	var resp struct {
+		Id string
+	}
+	if err := json.NewDecoder(respBody).Decode(&resp); err != nil {
+		return "", err
+	}
+	return resp.Id, nil
+}
+
+// ExecStart runs the exec instance. Unless detach is set the returned stream
+// carries the command's output until it exits; it is multiplexed unless the
+// exec was created with a TTY, see Demux.
+func (d *dockerClient) ExecStart(ctx context.Context, execId string, detach bool) (io.ReadCloser, error) {
+	if err := checkName(execId); err != nil {
+		return nil, err
+	}
+
+	// The daemon multiplexes the output unless the start request asks for a
+	// TTY, whatever the exec was created with.
+	exec, err := d.ExecInspect(ctx, execId)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/exec/%s/start", pathName(execId))
+		body   = map[string]bool{"Detach": detach, "Tty": exec.ProcessConfig.Tty}
+	)
+
+	respBody, err := d.newStreamRequest(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return respBody, nil
+}
+
+// ExecInspect reports whether the exec instance is still running and, once it
+// is done, its exit code, along with the process it started.
+func (d *dockerClient) ExecInspect(ctx context.Context, execId string) (*ExecInspectResult, error) {
+	if err := checkName(execId); err != nil {
+		return nil, err
 	}
-	if nocache {
-		v.Set("nocache", "1")
+
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/exec/%s/json", pathName(execId))
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
 	}
-	v.Set("rm", "1")
-	if forcerm {
-		v.Set("forcerm", "1")
+	defer respBody.Close()
+
+	var result *ExecInspectResult
+	if err := json.NewDecoder(respBody).Decode(&result); err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	uri = fmt.Sprintf("%s?%s", uri, v.Encode())
-	req, err := http.NewRequest(method, uri, ctx)
+// ContainerExecs inspects the exec instances of the container, including
+// those never started and those that are done but not yet cleaned up by the
+// daemon.
+func (d *dockerClient) ContainerExecs(ctx context.Context, id string) ([]*ExecInspectResult, error) {
+	container, err := d.FetchContainer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ExecInspectResult, 0, len(container.ExecIDs))
+	for _, execId := range container.ExecIDs {
+		result, err := d.ExecInspect(ctx, execId)
+		if err != nil {
+			// The daemon may have cleaned it up in the meantime.
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ContainerTop lists the processes running in the container. psArgs are passed
+// to ps and default to "-ef".
+func (d *dockerClient) ContainerTop(ctx context.Context, id string, psArgs string) (*TopResult, error) {
+	if err := checkName(id); err != nil {
+		return nil, err
+	}
+
+	if psArgs == "" {
+		psArgs = "-ef"
+	}
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/containers/%s/top?ps_args=%s", pathName(id), url.QueryEscape(psArgs))
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+
+	var top *TopResult
+	if err := json.NewDecoder(respBody).Decode(&top); err != nil {
+		return nil, err
+	}
+	return top, nil
+}
+
+// RenameContainer gives the container a new name. The error matches
+// ErrConflict if newName is already taken.
+func (d *dockerClient) RenameContainer(ctx context.Context, name, newName string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/rename?name=%s", pathName(name), url.QueryEscape(newName))
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return err
+	}
+	respBody.Close()
+
+	return nil
+}
+
+// UpdateContainer changes the resource limits of a container without
+// recreating it.
+func (d *dockerClient) UpdateContainer(ctx context.Context, name string, resources UpdateConfig) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/containers/%s/update", pathName(name))
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, resources)
 	if err != nil {
+		return err
+	}
+	respBody.Close()
+
+	return nil
+}
+
+// ContainerDiff lists the paths changed in the container's filesystem since it
+// was created.
+func (d *dockerClient) ContainerDiff(ctx context.Context, id string) ([]FileChange, error) {
+	if err := checkName(id); err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/tar")
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/containers/%s/changes", pathName(id))
+	)
 
-	c, err := d.newConn()
+	respBody, err := d.newRequest(ctx, method, uri, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer respBody.Close()
+
+	var changes []FileChange
+	if err := json.NewDecoder(respBody).Decode(&changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// CommitContainer creates an image from the container's current state and
+// returns its ID.
+func (d *dockerClient) CommitContainer(ctx context.Context, id string, opts CommitOptions) (string, error) {
+	var (
+		method = "POST"
+		v      = url.Values{}
+	)
+	v.Set("container", id)
+	if opts.Repo != "" {
+		v.Set("repo", opts.Repo)
+	}
+	if opts.Tag != "" {
+		v.Set("tag", opts.Tag)
+	}
+	if opts.Comment != "" {
+		v.Set("comment", opts.Comment)
+	}
+	if opts.Author != "" {
+		v.Set("author", opts.Author)
+	}
+	uri := fmt.Sprintf("/commit?%s", v.Encode())
 
-	resp, err := c.Do(req)
+	respBody, err := d.newRequest(ctx, method, uri, opts.Config)
 	if err != nil {
+		return "", err
+	}
+	defer respBody.Close()
+
+	var resp struct {
+		Id string
+	}
+	if err := json.NewDecoder(respBody).Decode(&resp); err != nil {
+		return "", err
+	}
+	return resp.Id, nil
+}
+
+// ExportContainer returns the container's filesystem as a tar archive.
+func (d *dockerClient) ExportContainer(ctx context.Context, id string) (io.ReadCloser, error) {
+	if err := checkName(id); err != nil {
 		return nil, err
 	}
 
-	if !d.isOkStatus(resp.StatusCode) {
-		return nil, fmt.Errorf("invalid HTTP request %d %s", resp.StatusCode, resp.Status)
+	var (
+		method = "GET"
+		uri    = fmt.Sprintf("/containers/%s/export", pathName(id))
+	)
+
+	respBody, err := d.newStreamRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return resp.Body, nil
+	return respBody, nil
 }
 
-func (d *dockerClient) DecodeStream(stream io.Reader) []string {
-	type msg struct {
-		Stream string `json:stream`
+// ImportImage creates an image from the filesystem tar archive read from src,
+// such as one produced by ExportContainer, and returns its ID.
+func (d *dockerClient) ImportImage(ctx context.Context, src io.Reader, repo, tag string) (string, error) {
+	var (
+		method = "POST"
+		v      = url.Values{}
+		header = http.Header{"Content-Type": {"application/x-tar"}}
+	)
+	v.Set("fromSrc", "-")
+	if repo != "" {
+		v.Set("repo", repo)
 	}
-	var msgs []string
-	dec := json.NewDecoder(stream)
+	if tag != "" {
+		v.Set("tag", tag)
+	}
+	uri := fmt.Sprintf("/images/create?%s", v.Encode())
+
+	respBody, err := d.newRawRequest(ctx, method, uri, header, src)
+	if err != nil {
+		return "", err
+	}
+	defer respBody.Close()
+
+	// The last status message holds the ID of the new image.
+	var id string
+	dec := json.NewDecoder(respBody)
 	for {
-		var m msg
+		var m progressMessage
 		if err := dec.Decode(&m); err != nil {
 			if err == io.EOF {
-				break
+				return id, nil
 			}
-			continue
+			return "", err
 		}
-		msgs = append(msgs, strings.TrimSuffix(m.Stream, "\n"))
+		if m.Error != "" {
+			return "", errors.New(m.Error)
+		}
+		id = m.Status
 	}
-	return msgs
 }
 
-func (d *dockerClient) RemoveImage(name string, force bool, noprune bool) (io.ReadCloser, error) {
+func (d *dockerClient) CreateVolume(ctx context.Context, opts VolumeOptions) (*VolumeInfo, error) {
 	var (
-		method = "DELETE"
-		uri    = fmt.Sprintf("/images/%s", name)
-		v      = &url.Values{}
+		method = "POST"
+		uri    = "/volumes/create"
 	)
-	if force {
-		v.Set("force", "1")
+
+	respBody, err := d.newRequest(ctx, method, uri, opts)
+	if err != nil {
+		return nil, err
 	}
-	if noprune {
-		v.Set("noprune", "1")
+	defer respBody.Close()
+
+	var volume *VolumeInfo
+	if err := json.NewDecoder(respBody).Decode(&volume); err != nil {
+		return nil, err
 	}
-	uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+	return volume, nil
+}
+
+func (d *dockerClient) ListVolumes(ctx context.Context) ([]*VolumeInfo, error) {
+	var (
+		method = "GET"
+		uri    = "/volumes"
+	)
 
-	respBody, err := d.newRequest(method, uri, nil)
+	respBody, err := d.newRequest(ctx, method, uri, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer respBody.Close()
 
-	return respBody, nil
+	var resp struct {
+		Volumes []*VolumeInfo
+	}
+	if err := json.NewDecoder(respBody).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return resp.Volumes, nil
 }
 
-func (d *dockerClient) ContainerWait(name string) error {
+func (d *dockerClient) InspectVolume(ctx context.Context, name string) (*VolumeInfo, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+
 	var (
-		method = "POST"
-		uri    = fmt.Sprintf("/containers/%s/wait", name)
+		method = "GET"
+		uri    = fmt.Sprintf("/volumes/%s", pathName(name))
 	)
 
-	respBody, err := d.newRequest(method, uri, nil)
+	respBody, err := d.newRequest(ctx, method, uri, nil)
 	if err != nil {
+		return nil, err
+	}
+	defer respBody.Close()
+
+	var volume *VolumeInfo
+	if err := json.NewDecoder(respBody).Decode(&volume); err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// RemoveVolume removes the named volume. The error matches ErrConflict if the
+// volume is still in use by a container.
+func (d *dockerClient) RemoveVolume(ctx context.Context, name string, force bool) error {
+	if err := checkName(name); err != nil {
 		return err
 	}
 
-	defer respBody.Close()
+	var (
+		method = "DELETE"
+		uri    = fmt.Sprintf("/volumes/%s?force=%v", pathName(name), force)
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return err
+	}
+	respBody.Close()
 
-	ioutil.ReadAll(respBody)
 	return nil
 }
 
-func (d *dockerClient) Attach(name string, logs, stream, stdout, stderr bool, inStream io.Writer) (io.Reader, io.Writer, error) {
+// CreateNetwork creates a network and returns its ID.
+func (d *dockerClient) CreateNetwork(ctx context.Context, opts NetworkOptions) (string, error) {
 	var (
-		//method = "POST"
-		uri = fmt.Sprintf("/containers/%s/attach", name)
+		method = "POST"
+		uri    = "/networks/create"
 	)
-	var v url.Values
-	if logs {
-		v.Set("logs", "1")
+
+	respBody, err := d.newRequest(ctx, method, uri, opts)
+	if err != nil {
+		return "", err
+	}
+	defer respBody.Close()
+
+	var resp struct {
+		Id string
+	}
+	if err := json.NewDecoder(respBody).Decode(&resp); err != nil {
+		return "", err
 	}
-	if stream {
-		v.Set("stream", "1")
+	return resp.Id, nil
+}
+
+func (d *dockerClient) ListNetworks(ctx context.Context) ([]*Network, error) {
+	var (
+		method = "GET"
+		uri    = "/networks"
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
 	}
-	if inStream != nil {
-		v.Set("stdin", "1")
+	defer respBody.Close()
+
+	var networks []*Network
+	if err := json.NewDecoder(respBody).Decode(&networks); err != nil {
+		return nil, err
 	}
-	if stdout {
-		v.Set("stdout", "1")
+	return networks, nil
+}
+
+func (d *dockerClient) ConnectNetwork(ctx context.Context, networkId, containerId string) error {
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/networks/%s/connect", url.PathEscape(networkId))
+		body   = map[string]string{"Container": containerId}
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, body)
+	if err != nil {
+		return err
 	}
-	if stderr {
-		v.Set("stderr", "1")
+	respBody.Close()
+
+	return nil
+}
+
+func (d *dockerClient) DisconnectNetwork(ctx context.Context, networkId, containerId string, force bool) error {
+	var (
+		method = "POST"
+		uri    = fmt.Sprintf("/networks/%s/disconnect", url.PathEscape(networkId))
+		body   = map[string]interface{}{"Container": containerId, "Force": force}
+	)
+
+	respBody, err := d.newRequest(ctx, method, uri, body)
+	if err != nil {
+		return err
 	}
-	uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+	respBody.Close()
+
+	return nil
+}
 
-	//respBody, conn, err := d.newRequest(method, uri, inStream)
-	return nil, nil, nil
+// PruneContainers removes all stopped containers matching filters.
+func (d *dockerClient) PruneContainers(ctx context.Context, filters map[string][]string) (*PruneReport, error) {
+	return d.prune(ctx, "/containers/prune", filters)
 }
 
-func (d *dockerClient) ContainerStats(name string) (io.ReadCloser, error) {
+// PruneImages removes all dangling images matching filters, or all unused
+// ones with the filter "dangling=false".
+func (d *dockerClient) PruneImages(ctx context.Context, filters map[string][]string) (*PruneReport, error) {
+	return d.prune(ctx, "/images/prune", filters)
+}
+
+// PruneVolumes removes all unused volumes matching filters.
+func (d *dockerClient) PruneVolumes(ctx context.Context, filters map[string][]string) (*PruneReport, error) {
+	return d.prune(ctx, "/volumes/prune", filters)
+}
+
+func (d *dockerClient) prune(ctx context.Context, uri string, filters map[string][]string) (*PruneReport, error) {
 	var (
-		method = "GET"
-		uri    = fmt.Sprintf("/containers/%s/stats", name)
+		method = "POST"
+		v      = url.Values{}
 	)
+	if err := setFilters(v, filters); err != nil {
+		return nil, err
+	}
+	if len(v) > 0 {
+		uri = fmt.Sprintf("%s?%s", uri, v.Encode())
+	}
 
-	respBody, err := d.newRequest(method, uri, nil)
+	respBody, err := d.newRequest(ctx, method, uri, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer respBody.Close()
 
-	return respBody, nil
+	var resp pruneResponse
+	if err := json.NewDecoder(respBody).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return resp.report(), nil
+}
+
+// ResizeContainerTTY resizes the TTY of a container started with Tty set.
+func (d *dockerClient) ResizeContainerTTY(ctx context.Context, id string, height, width int) error {
+	if err := checkName(id); err != nil {
+		return err
+	}
+
+	return d.resizeTTY(ctx, fmt.Sprintf("/containers/%s/resize", pathName(id)), height, width)
+}
+
+// ResizeExecTTY resizes the TTY of an exec instance created with Tty set.
+func (d *dockerClient) ResizeExecTTY(ctx context.Context, execId string, height, width int) error {
+	if err := checkName(execId); err != nil {
+		return err
+	}
+
+	return d.resizeTTY(ctx, fmt.Sprintf("/exec/%s/resize", pathName(execId)), height, width)
+}
+
+func (d *dockerClient) resizeTTY(ctx context.Context, uri string, height, width int) error {
+	var method = "POST"
+	uri = fmt.Sprintf("%s?h=%d&w=%d", uri, height, width)
+
+	respBody, err := d.newRequest(ctx, method, uri, nil)
+	if err != nil {
+		return err
+	}
+	respBody.Close()
+
+	return nil
 }
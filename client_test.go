@@ -0,0 +1,878 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client served by handler, closed with the test.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...ClientOption) Docker {
+	t.Helper()
+	client, err := NewTestClient(handler, opts...)
+	if err != nil {
+		t.Fatalf("NewTestClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestConcurrentFetchContainer(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, rest, _ := strings.Cut(r.URL.Path, "/containers/")
+		name, ok := strings.CutSuffix(rest, "/json")
+		if r.Method != "GET" || !ok {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Id":    "id-" + name,
+			"Name":  "/" + name,
+			"State": map[string]interface{}{"Status": "running", "Running": true},
+		})
+	})
+
+	const workers, calls = 16, 25
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, workers*calls)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < calls; j++ {
+				name := fmt.Sprintf("c%d-%d", i, j)
+				container, err := client.FetchContainer(context.Background(), name)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if container.Id != "id-"+name || !container.State.Running {
+					errs <- fmt.Errorf("%s: got %+v", name, container)
+				}
+			}
+		}(i)
+	}
+
+	// Settings may be changed while requests are in flight.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < calls; i++ {
+			client.SetRetryPolicy(RetryPolicy{MaxAttempts: i%3 + 1, BaseDelay: time.Millisecond})
+			client.SetTlsConfig(nil)
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// infoPayload is a trimmed /info response of a 24.0 daemon.
+const infoPayload = `{
+  "ID": "5b1d1c4e-3f0a-4a7e-9d55-6f1a0c8e2b7d",
+  "Containers": 3,
+  "ContainersRunning": 1,
+  "ContainersPaused": 0,
+  "ContainersStopped": 2,
+  "Images": 12,
+  "Driver": "overlay2",
+  "DriverStatus": [["Backing Filesystem", "extfs"], ["Supports d_type", "true"]],
+  "Plugins": {"Volume": ["local"], "Network": ["bridge", "host", "null"]},
+  "MemoryLimit": true,
+  "SwapLimit": false,
+  "CpuCfsPeriod": true,
+  "IPv4Forwarding": true,
+  "Debug": false,
+  "NFd": 38,
+  "NGoroutines": 51,
+  "NEventsListener": 0,
+  "KernelVersion": "6.5.0-14-generic",
+  "OperatingSystem": "Ubuntu 22.04.3 LTS",
+  "OSType": "linux",
+  "Architecture": "x86_64",
+  "IndexServerAddress": "https://index.docker.io/v1/",
+  "RegistryConfig": {"IndexConfigs": {"docker.io": {"Name": "docker.io", "Secure": true}}},
+  "NCPU": 8,
+  "MemTotal": 16624427008,
+  "DockerRootDir": "/var/lib/docker",
+  "Name": "build-01",
+  "Labels": [],
+  "ServerVersion": "24.0.7",
+  "Warnings": ["WARNING: No swap limit support"]
+}`
+
+func TestDaemonInfoUnmarshal(t *testing.T) {
+	var info DaemonInfo
+	if err := json.Unmarshal([]byte(infoPayload), &info); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if info.Debug || !info.MemoryLimit || info.SwapLimit || !info.IPv4Forwarding {
+		t.Errorf("flags: got Debug=%t MemoryLimit=%t SwapLimit=%t IPv4Forwarding=%t",
+			info.Debug, info.MemoryLimit, info.SwapLimit, info.IPv4Forwarding)
+	}
+	if info.ServerVersion != "24.0.7" || info.NCPU != 8 || info.MemTotal != 16624427008 || info.ContainersStopped != 2 {
+		t.Errorf("got %+v", info)
+	}
+	if len(info.Warnings) != 1 {
+		t.Errorf("got warnings %q", info.Warnings)
+	}
+}
+
+func TestDaemonInfoUnmarshalFlags(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    bool
+		wantErr bool
+	}{
+		{`{"Debug": true}`, true, false},
+		{`{"Debug": false}`, false, false},
+		{`{"Debug": 1}`, true, false},
+		{`{"Debug": 0}`, false, false},
+		{`{"Debug": null}`, false, false},
+		{`{}`, false, false},
+		{`{"Debug": 2}`, false, true},
+		{`{"Debug": "true"}`, false, true},
+	}
+	for _, tt := range tests {
+		var info DaemonInfo
+		err := json.Unmarshal([]byte(tt.json), &info)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v", tt.json, err)
+			continue
+		}
+		if err == nil && info.Debug != tt.want {
+			t.Errorf("%s: got Debug=%t, want %t", tt.json, info.Debug, tt.want)
+		}
+	}
+}
+
+func TestInfoOldDaemon(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/info") {
+			http.NotFound(w, r)
+			return
+		}
+		// Daemons before 1.10 sent the flags as integers.
+		io.WriteString(w, `{"Containers":1,"Images":4,"Driver":"aufs","Debug":1,"MemoryLimit":1,"SwapLimit":0,"IPv4Forwarding":1,"NGoroutines":11}`)
+	})
+
+	info, err := client.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if !info.Debug || !info.MemoryLimit || info.SwapLimit || !info.IPv4Forwarding || info.Driver != "aufs" {
+		t.Errorf("got %+v", info)
+	}
+}
+
+func TestIsOkStatus(t *testing.T) {
+	var d dockerClient
+	for code, want := range map[int]bool{
+		101: true, 200: true, 201: true, 204: true, 299: true,
+		100: false, 301: false, 304: false, 400: false, 404: false, 409: false, 500: false,
+	} {
+		if got := d.isOkStatus(code); got != want {
+			t.Errorf("isOkStatus(%d) = %t, want %t", code, got, want)
+		}
+	}
+}
+
+func TestContainerActionStatus(t *testing.T) {
+	ctx := context.Background()
+	actions := map[string]func(Docker) error{
+		"start":   func(d Docker) error { return d.StartContainer(ctx, "web", nil) },
+		"stop":    func(d Docker) error { return d.StopContainer(ctx, "web", 10) },
+		"restart": func(d Docker) error { return d.RestartContainer(ctx, "web", 10) },
+		"kill":    func(d Docker) error { return d.KillContainer(ctx, "web", "") },
+		"pause":   func(d Docker) error { return d.PauseContainer(ctx, "web") },
+		"unpause": func(d Docker) error { return d.UnpauseContainer(ctx, "web") },
+	}
+
+	// The status codes documented for each endpoint. want is the sentinel
+	// the error must match, apiStatus the status of the *APIError it must
+	// wrap, both unset for success.
+	tests := []struct {
+		action    string
+		status    int
+		message   string
+		want      error
+		apiStatus int
+	}{
+		{"start", 204, "", nil, 0},
+		{"start", 304, "", ErrAlreadyStarted, 0},
+		{"start", 404, "No such container: web", ErrNotFound, 404},
+		{"start", 409, "conflict", ErrConflict, 409},
+		{"start", 500, "server error", nil, 500},
+
+		{"stop", 204, "", nil, 0},
+		{"stop", 304, "", nil, 0},
+		{"stop", 404, "No such container: web", ErrNotFound, 404},
+		{"stop", 409, "conflict", ErrConflict, 409},
+		{"stop", 500, "server error", nil, 500},
+
+		{"restart", 204, "", nil, 0},
+		{"restart", 404, "No such container: web", ErrNotFound, 404},
+		{"restart", 500, "server error", nil, 500},
+
+		{"kill", 204, "", nil, 0},
+		{"kill", 404, "No such container: web", ErrNotFound, 404},
+		{"kill", 409, "Container web is not running", ErrConflict, 409},
+		{"kill", 500, "server error", nil, 500},
+
+		{"pause", 204, "", nil, 0},
+		{"pause", 404, "No such container: web", ErrNotFound, 404},
+		{"pause", 409, "Container web is not running", ErrNotRunning, 409},
+		{"pause", 409, "Container web is already paused", ErrAlreadyPaused, 409},
+		{"pause", 500, "server error", nil, 500},
+
+		{"unpause", 204, "", nil, 0},
+		{"unpause", 404, "No such container: web", ErrNotFound, 404},
+		{"unpause", 409, "Container web is not paused", ErrNotPaused, 409},
+		{"unpause", 500, "server error", nil, 500},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.action, tt.status), func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || !strings.HasSuffix(r.URL.Path, "/containers/web/"+tt.action) {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tt.status)
+				if tt.message != "" {
+					json.NewEncoder(w).Encode(map[string]string{"message": tt.message})
+				}
+			})
+
+			err := actions[tt.action](client)
+			switch {
+			case tt.want == nil && tt.apiStatus == 0:
+				if err != nil {
+					t.Fatalf("got error %v", err)
+				}
+			case err == nil:
+				t.Fatal("got no error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want it to match %v", err, tt.want)
+			}
+			if tt.apiStatus != 0 && !isStatus(err, tt.apiStatus) {
+				t.Errorf("got %v, want an APIError with status %d", err, tt.apiStatus)
+			}
+		})
+	}
+}
+
+func TestCreateContainerName(t *testing.T) {
+	var (
+		query url.Values
+		body  map[string]interface{}
+	)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || !strings.HasSuffix(r.URL.Path, "/containers/create") {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		query, body = r.URL.Query(), nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id":"4a2f9c","Warnings":[]}`)
+	})
+
+	id, err := client.CreateContainer(context.Background(), map[string]interface{}{
+		"Name":  "web_1.blue",
+		"Image": "busybox",
+	})
+	if err != nil {
+		t.Fatalf("CreateContainer: %v", err)
+	}
+	if id != "4a2f9c" {
+		t.Errorf("got id %q", id)
+	}
+	if got := query.Get("name"); got != "web_1.blue" {
+		t.Errorf("got name %q in the query", got)
+	}
+	if _, ok := body["Name"]; ok || body["Image"] != "busybox" {
+		t.Errorf("got body %v", body)
+	}
+
+	if _, err := client.CreateContainerWithConfig(context.Background(), ContainerConfig{Image: "busybox"}, HostConfig{}, "db"); err != nil {
+		t.Fatalf("CreateContainerWithConfig: %v", err)
+	}
+	if got := query.Get("name"); got != "db" {
+		t.Errorf("got name %q in the query", got)
+	}
+
+	if _, err := client.CreateContainer(context.Background(), map[string]interface{}{"Image": "busybox"}); err != nil {
+		t.Fatalf("CreateContainer: %v", err)
+	}
+	if _, ok := query["name"]; ok {
+		t.Errorf("got name %q in the query without one asked for", query.Get("name"))
+	}
+}
+
+func TestStartContainerEmptyBody(t *testing.T) {
+	var starts int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1.44/containers/create":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Id":"4a2f9c","Warnings":[]}`)
+		case r.Method == "POST" && r.URL.Path == "/v1.44/containers/4a2f9c/start":
+			// Like daemons since API 1.24, refuse a host config here.
+			b, _ := io.ReadAll(r.Body)
+			if len(b) != 0 || r.Header.Get("Content-Type") != "" {
+				http.Error(w, `{"message":"starting container with non-empty request body was deprecated since API v1.22 and removed in v1.24"}`, http.StatusBadRequest)
+				return
+			}
+			starts++
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}, WithVersion("1.44"))
+
+	ctx := context.Background()
+	if err := client.StartContainer(ctx, "4a2f9c", nil); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	if _, err := client.RunContainerWithConfig(ctx, ContainerConfig{Image: "busybox"}, HostConfig{Binds: []string{"/srv:/srv"}}, ""); err != nil {
+		t.Fatalf("RunContainerWithConfig: %v", err)
+	}
+	if _, err := client.RunContainer(ctx, map[string]interface{}{"Image": "busybox", "HostConfig": map[string]interface{}{"Privileged": true}}); err != nil {
+		t.Fatalf("RunContainer: %v", err)
+	}
+	if starts != 3 {
+		t.Errorf("got %d starts, want 3", starts)
+	}
+}
+
+func TestContainerEnvCmdRoundTrip(t *testing.T) {
+	// A daemon keeping the config of the containers it created.
+	var (
+		mu      sync.Mutex
+		configs = map[string]json.RawMessage{}
+	)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/containers/create"):
+			var cfg json.RawMessage
+			if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			id := fmt.Sprintf("c%d", len(configs))
+			configs[id] = cfg
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"Id":%q}`, id)
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/json"):
+			_, rest, _ := strings.Cut(r.URL.Path, "/containers/")
+			id := strings.TrimSuffix(rest, "/json")
+			cfg, ok := configs[id]
+			if !ok {
+				http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, `{"Id":%q,"Config":%s}`, id, cfg)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	})
+
+	var (
+		ctx = context.Background()
+		env = []string{"A=1", "B=x=y", "C=with space", "EMPTY="}
+		cmd = []string{"sh", "-c", `echo "$A" && exit 3`}
+	)
+	check := func(id string) {
+		t.Helper()
+		container, err := client.FetchContainer(ctx, id)
+		if err != nil {
+			t.Fatalf("FetchContainer: %v", err)
+		}
+		if !reflect.DeepEqual(container.Config.Env, env) {
+			t.Errorf("got Env %q, want %q", container.Config.Env, env)
+		}
+		if !reflect.DeepEqual(container.Config.Cmd, cmd) {
+			t.Errorf("got Cmd %q, want %q", container.Config.Cmd, cmd)
+		}
+	}
+
+	id, err := client.CreateContainerWithConfig(ctx, ContainerConfig{Image: "busybox", Env: env, Cmd: cmd}, HostConfig{}, "")
+	if err != nil {
+		t.Fatalf("CreateContainerWithConfig: %v", err)
+	}
+	check(id)
+
+	id, err = client.CreateContainer(ctx, map[string]interface{}{"Image": "busybox", "Env": env, "Cmd": cmd})
+	if err != nil {
+		t.Fatalf("CreateContainer: %v", err)
+	}
+	check(id)
+
+	_, err = client.CreateContainerWithConfig(ctx, ContainerConfig{Image: "busybox", Env: []string{"NOVALUE"}}, HostConfig{}, "")
+	if err == nil {
+		t.Error("got no error for an Env entry without =")
+	}
+}
+
+// writeFrame writes payload as one frame of a multiplexed stream.
+func writeFrame(w io.Writer, stream byte, payload string) {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	w.Write(header)
+	io.WriteString(w, payload)
+}
+
+func TestLogsShortLivedContainer(t *testing.T) {
+	const lines = 500
+
+	// A container printing lines to stdout, every tenth to stderr, and
+	// exiting right away. Its log is sent in frames which split lines, as the
+	// daemon does for output written in pieces. Following the log of a
+	// container which was not started yet waits for it to start.
+	var (
+		mu       sync.Mutex
+		created  bool
+		started  = make(chan struct{})
+		notFound sync.Once
+		missed   = make(chan struct{})
+	)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		exists := created
+		mu.Unlock()
+
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/containers/create"):
+			mu.Lock()
+			created = true
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Id":"job"}`)
+		case !exists:
+			if strings.HasSuffix(r.URL.Path, "/logs") {
+				notFound.Do(func() { close(missed) })
+			}
+			http.Error(w, `{"message":"No such container: job"}`, http.StatusNotFound)
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/containers/job/start"):
+			close(started)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/containers/job/json"):
+			status := "created"
+			select {
+			case <-started:
+				status = "exited"
+			default:
+			}
+			fmt.Fprintf(w, `{"Id":"job","State":{"Status":%q,"ExitCode":0}}`, status)
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/containers/job/logs"):
+			if r.URL.Query().Get("tail") != "all" {
+				http.Error(w, "unexpected tail", http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			if r.URL.Query().Get("follow") == "true" {
+				select {
+				case <-started:
+				case <-r.Context().Done():
+					return
+				}
+			}
+			for i := 0; i < lines; i++ {
+				stream, line := byte(streamStdout), fmt.Sprintf("line %d\n", i)
+				if i%10 == 0 {
+					stream = streamStderr
+				}
+				writeFrame(w, stream, line[:3])
+				writeFrame(w, stream, line[3:])
+				if i%50 == 0 {
+					w.(http.Flusher).Flush()
+				}
+			}
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// readAll collects the log of the container, failing unless the error
+	// channel closes without an error.
+	readAll := func(follow bool) ([]*LogLine, error) {
+		logs, errs, err := client.ContainerLogLines(ctx, "job", follow, true, true, false, -1, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		var got []*LogLine
+		for l := range logs {
+			got = append(got, l)
+		}
+		return got, <-errs
+	}
+	check := func(got []*LogLine) {
+		t.Helper()
+		if len(got) != lines {
+			t.Fatalf("got %d lines, want %d", len(got), lines)
+		}
+		for i, l := range got {
+			stream := "stdout"
+			if i%10 == 0 {
+				stream = "stderr"
+			}
+			if want := fmt.Sprintf("line %d", i); l.Line != want || l.Stream != stream {
+				t.Fatalf("line %d: got %s %q, want %s %q", i, l.Stream, l.Line, stream, want)
+			}
+		}
+	}
+
+	type result struct {
+		lines []*LogLine
+		err   error
+	}
+	followed := make(chan result, 1)
+	go func() {
+		lines, err := readAll(true)
+		followed <- result{lines, err}
+	}()
+	<-missed
+
+	id, err := client.RunContainerWithConfig(ctx, ContainerConfig{Image: "busybox"}, HostConfig{}, "job")
+	if err != nil {
+		t.Fatalf("RunContainerWithConfig: %v", err)
+	}
+	if code, err := client.WaitForExit(ctx, id, 0); err != nil || code != 0 {
+		t.Fatalf("WaitForExit: got %d, %v", code, err)
+	}
+
+	got, err := readAll(false)
+	if err != nil {
+		t.Fatalf("reading the log: %v", err)
+	}
+	check(got)
+
+	res := <-followed
+	if res.err != nil {
+		t.Fatalf("following the log: %v", res.err)
+	}
+	check(res.lines)
+
+	all, err := client.ContainerLogsAll(ctx, id, true, true, false, -1)
+	if err != nil {
+		t.Fatalf("ContainerLogsAll: %v", err)
+	}
+	if n := strings.Count(all, "\n"); n != lines || !strings.HasPrefix(all, "line 0\nline 1\n") {
+		t.Errorf("ContainerLogsAll: got %d lines starting with %q", n, all[:min(len(all), 20)])
+	}
+}
+
+func TestSlashPrefixedName(t *testing.T) {
+	var path string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		io.WriteString(w, `{"Id":"e1"}`)
+	})
+
+	ctx := context.Background()
+	tests := []struct {
+		path string
+		call func() error
+	}{
+		{"/containers/web/stop", func() error { return client.StopContainer(ctx, "/web", 1) }},
+		{"/containers/web/start", func() error { return client.StartContainer(ctx, "/web", nil) }},
+		{"/containers/web", func() error { return client.RemoveContainer(ctx, "/web", true, false) }},
+		{"/containers/web/kill", func() error { return client.KillContainer(ctx, "/web", "") }},
+		{"/containers/web/pause", func() error { return client.PauseContainer(ctx, "/web") }},
+		{"/containers/web/logs", func() error {
+			_, err := client.ContainerLogsAll(ctx, "/web", true, true, false, -1)
+			return err
+		}},
+		{"/containers/web/exec", func() error {
+			_, err := client.ExecCreate(ctx, "/web", []string{"true"}, ExecConfig{})
+			return err
+		}},
+		{"/containers/web/archive", func() error {
+			body, err := client.CopyFromContainer(ctx, "/web", "/etc/hosts")
+			if err == nil {
+				body.Close()
+			}
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		if path != tt.path {
+			t.Errorf("got request to %s, want %s", path, tt.path)
+		}
+	}
+}
+
+func TestDoRelativePath(t *testing.T) {
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		io.WriteString(w, "[]")
+	})
+
+	if _, err := client.Do(context.Background(), "GET", "containers/json", nil); err == nil {
+		t.Error("got no error for a path without a leading slash")
+	}
+	if requests != 0 {
+		t.Errorf("got %d requests, want none", requests)
+	}
+}
+
+func TestWaitForExitTimeout(t *testing.T) {
+	// A running container whose inspect takes slowInspect, and which does
+	// not exit.
+	var slowInspect time.Duration
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			select {
+			case <-time.After(slowInspect):
+			case <-r.Context().Done():
+				return
+			}
+			io.WriteString(w, `{"Id":"x","State":{"Status":"running","Running":true}}`)
+		case strings.HasSuffix(r.URL.Path, "/wait"):
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}
+	ctx := context.Background()
+
+	client := newTestClient(t, handler)
+	if _, err := client.WaitForExit(ctx, "x", 100*time.Millisecond); !errors.Is(err, ErrStillRunning) {
+		t.Errorf("got %v, want ErrStillRunning", err)
+	}
+
+	// The client timeout expiring before the state was read is not.
+	slowInspect = time.Second
+	client = newTestClient(t, handler, WithTimeout(50*time.Millisecond))
+	_, err := client.WaitForExit(ctx, "x", time.Minute)
+	if errors.Is(err, ErrStillRunning) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the client timeout", err)
+	}
+}
+
+func TestRunAndWaitTimeout(t *testing.T) {
+	// A container which does not exit, and whose start takes slowStart.
+	var slowStart time.Duration
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Id":"x"}`)
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			select {
+			case <-time.After(slowStart):
+			case <-r.Context().Done():
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/wait"):
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}
+	ctx := context.Background()
+
+	client := newTestClient(t, handler)
+	if _, err := client.RunAndWait(ctx, ContainerConfig{Image: "busybox"}, HostConfig{}, 100*time.Millisecond, true); !errors.Is(err, ErrStillRunning) {
+		t.Errorf("got %v, want ErrStillRunning", err)
+	}
+
+	// A start running into the client timeout never got the container going.
+	slowStart = time.Second
+	client = newTestClient(t, handler, WithTimeout(50*time.Millisecond))
+	_, err := client.RunAndWait(ctx, ContainerConfig{Image: "busybox"}, HostConfig{}, time.Minute, true)
+	if errors.Is(err, ErrStillRunning) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the client timeout", err)
+	}
+}
+
+func TestExecStartTty(t *testing.T) {
+	// Exec "tty" was created with a TTY, "plain" without. Like the daemon,
+	// the output is multiplexed unless the start request asks for a TTY.
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, rest, _ := strings.Cut(r.URL.Path, "/exec/")
+		id, action, _ := strings.Cut(rest, "/")
+		switch action {
+		case "json":
+			fmt.Fprintf(w, `{"ID":%q,"Running":false,"ProcessConfig":{"entrypoint":"echo","arguments":["hello"],"tty":%t}}`, id, id == "tty")
+		case "start":
+			var body struct{ Detach, Tty bool }
+			json.NewDecoder(r.Body).Decode(&body)
+			w.WriteHeader(http.StatusOK)
+			if body.Tty {
+				io.WriteString(w, "hello\r\n")
+			} else {
+				writeFrame(w, streamStdout, "hello\n")
+			}
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	})
+
+	for id, want := range map[string]string{"tty": "hello\r\n", "plain": "\x01\x00\x00\x00\x00\x00\x00\x06hello\n"} {
+		out, err := client.ExecStart(context.Background(), id, false)
+		if err != nil {
+			t.Fatalf("ExecStart %s: %v", id, err)
+		}
+		got, err := io.ReadAll(out)
+		out.Close()
+		if err != nil || string(got) != want {
+			t.Errorf("ExecStart %s: got %q, %v, want %q", id, got, err, want)
+		}
+	}
+}
+
+func TestWaitHealthyNoTimeout(t *testing.T) {
+	var inspects int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		inspects++
+		health := "starting"
+		if inspects > 1 {
+			health = "healthy"
+		}
+		fmt.Fprintf(w, `{"Id":"x","State":{"Status":"running","Running":true,"Health":{"Status":%q}}}`, health)
+	})
+
+	if err := client.WaitHealthy(context.Background(), "x", 0); err != nil {
+		t.Errorf("WaitHealthy: %v", err)
+	}
+}
+
+func TestWaitRunningNoTimeout(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"Id":"x","State":{"Status":"running","Running":true},"HostConfig":{"NetworkMode":"host"}}`)
+	})
+
+	if _, err := client.WaitRunning(context.Background(), "x", 0); err != nil {
+		t.Errorf("WaitRunning: %v", err)
+	}
+}
+
+func TestGetEventsReconnectDaemonClock(t *testing.T) {
+	// The daemon's clock is an hour behind ours, and the first stream ends
+	// before any event.
+	daemonNow := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var (
+		requests int
+		since    = make(chan string, 1)
+	)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Date", daemonNow.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if requests == 2 {
+			since <- r.URL.Query().Get("since")
+			<-r.Context().Done()
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.GetEventsReconnect(ctx)
+
+	select {
+	case got := <-since:
+		if want := formatTimestamp(daemonNow); got != want {
+			t.Errorf("reconnected with since=%s, want the daemon's %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnect")
+	}
+}
+
+func TestAutoRemove(t *testing.T) {
+	// A daemon removing the container as soon as it exited, so a wait sent
+	// after the start would not find it.
+	var (
+		mu       sync.Mutex
+		requests []string
+		body     map[string]interface{}
+		started  = make(chan struct{})
+	)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			json.NewDecoder(r.Body).Decode(&body)
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"Id":"x"}`)
+		case strings.HasSuffix(r.URL.Path, "/containers/x/wait"):
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			select {
+			case <-started:
+				io.WriteString(w, `{"StatusCode":3}`)
+			case <-r.Context().Done():
+			}
+		case strings.HasSuffix(r.URL.Path, "/containers/x/start"):
+			close(started)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	})
+
+	code, err := client.RunAndWait(context.Background(), ContainerConfig{Image: "busybox"}, HostConfig{AutoRemove: true}, time.Minute, true)
+	if err != nil || code != 3 {
+		t.Fatalf("RunAndWait: got %d, %v, want 3", code, err)
+	}
+
+	host, _ := body["HostConfig"].(map[string]interface{})
+	if host["AutoRemove"] != true {
+		t.Errorf("got HostConfig %v, want AutoRemove set", body["HostConfig"])
+	}
+	if _, ok := body["AutoRemove"]; ok {
+		t.Error("AutoRemove sent outside of HostConfig")
+	}
+
+	want := []string{
+		"POST /containers/create",
+		"POST /containers/x/wait?condition=next-exit",
+		"POST /containers/x/start",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
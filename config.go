@@ -0,0 +1,345 @@
+package docker
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContainerConfig is the portable configuration of a container, used with
+// CreateContainerWithConfig.
+type ContainerConfig struct {
+	Image string
+	// Cmd and Entrypoint hold one argument per element, they are not split
+	// by a shell.
+	Cmd        []string `json:",omitempty"`
+	Entrypoint []string `json:",omitempty"`
+	// Env holds variables in the KEY=VALUE form.
+	Env          []string            `json:",omitempty"`
+	ExposedPorts map[string]struct{} `json:",omitempty"`
+	Labels       map[string]string   `json:",omitempty"`
+	Hostname     string              `json:",omitempty"`
+	User         string              `json:",omitempty"`
+	WorkingDir   string              `json:",omitempty"`
+	Tty          bool                `json:",omitempty"`
+	OpenStdin    bool                `json:",omitempty"`
+	AttachStdin  bool                `json:",omitempty"`
+	AttachStdout bool                `json:",omitempty"`
+	AttachStderr bool                `json:",omitempty"`
+	// StopSignal is sent to stop the container instead of SIGTERM, e.g.
+	// "SIGINT". StopTimeout is the grace period in seconds before it is
+	// killed, the daemon's default if nil.
+	StopSignal  string        `json:",omitempty"`
+	StopTimeout *int          `json:",omitempty"`
+	Healthcheck *HealthConfig `json:",omitempty"`
+	// Platform selects the variant of the image to run, e.g. "linux/arm64",
+	// on hosts able to run several. It is also used to pull the image if it
+	// is missing.
+	Platform string `json:"-"`
+}
+
+// HostConfig is the host specific configuration of a container, used with
+// CreateContainerWithConfig.
+type HostConfig struct {
+	// Binds holds bind mounts and named volumes in the -v flag form of
+	// docker run, "source:target[:options]", e.g. "/srv/data:/data:ro".
+	Binds []string `json:",omitempty"`
+	// Mounts is the structured alternative to Binds.
+	Mounts       []Mount              `json:",omitempty"`
+	PortBindings map[string][]Binding `json:",omitempty"`
+	// Ports are published like with the -p flag of docker run, see
+	// ParsePortSpec. They are merged into PortBindings and the exposed ports
+	// of the container config on create.
+	Ports         []string       `json:"-"`
+	RestartPolicy *RestartPolicy `json:",omitempty"`
+	Memory        int64          `json:",omitempty"`
+	// AutoRemove has the daemon remove the container once it exited, like
+	// the --rm flag of docker run.
+	AutoRemove bool `json:",omitempty"`
+	// NetworkMode is "bridge", "host", "none", "container:<name|id>" to
+	// share the network of another container, or the name of a network.
+	NetworkMode string `json:",omitempty"`
+}
+
+// validate catches mistakes in the container config before it is sent.
+func (c ContainerConfig) validate() error {
+	for _, env := range c.Env {
+		if i := strings.Index(env, "="); i <= 0 {
+			return fmt.Errorf("invalid environment variable %q: expected KEY=VALUE", env)
+		}
+	}
+	if c.StopTimeout != nil && *c.StopTimeout < 0 {
+		return fmt.Errorf("invalid stop timeout %d", *c.StopTimeout)
+	}
+	if c.Healthcheck != nil {
+		return c.Healthcheck.validate()
+	}
+	return nil
+}
+
+// HealthConfig is the health check of a container. Test is either
+// ["NONE"] to disable the check of the image, ["CMD", args...] to run a
+// command or ["CMD-SHELL", command] to run it through the shell; an empty
+// Test inherits the check of the image. Zero durations and retries use the
+// daemon's defaults.
+type HealthConfig struct {
+	Test        []string      `json:",omitempty"`
+	Interval    time.Duration `json:",omitempty"`
+	Timeout     time.Duration `json:",omitempty"`
+	StartPeriod time.Duration `json:",omitempty"`
+	Retries     int           `json:",omitempty"`
+}
+
+func (h HealthConfig) validate() error {
+	if len(h.Test) > 0 {
+		switch h.Test[0] {
+		case "NONE", "CMD", "CMD-SHELL":
+		default:
+			return fmt.Errorf("invalid health check test %q: must start with NONE, CMD or CMD-SHELL", h.Test)
+		}
+	}
+	// The daemon rejects durations below a millisecond other than zero.
+	for _, d := range []time.Duration{h.Interval, h.Timeout, h.StartPeriod} {
+		if d < 0 || (d > 0 && d < time.Millisecond) {
+			return fmt.Errorf("invalid health check duration %s: must be zero or at least 1ms", d)
+		}
+	}
+	if h.Retries < 0 {
+		return fmt.Errorf("invalid health check retries %d", h.Retries)
+	}
+	return nil
+}
+
+// validate catches mistakes in the host config before it is sent.
+func (h HostConfig) validate() error {
+	if h.RestartPolicy != nil {
+		if err := h.RestartPolicy.validate(); err != nil {
+			return err
+		}
+	}
+	if name, ok := strings.CutPrefix(h.NetworkMode, "container:"); ok && name == "" {
+		return fmt.Errorf("invalid network mode %q: no container", h.NetworkMode)
+	}
+	for _, bind := range h.Binds {
+		if err := validateBind(bind); err != nil {
+			return err
+		}
+	}
+	for _, m := range h.Mounts {
+		if err := m.validate(); err != nil {
+			return err
+		}
+	}
+	if h.AutoRemove && h.RestartPolicy != nil && h.RestartPolicy.Name != "" && h.RestartPolicy.Name != RestartNo {
+		return fmt.Errorf("auto remove conflicts with restart policy %q", h.RestartPolicy.Name)
+	}
+	return nil
+}
+
+// networkContainer returns the container whose network is shared by the
+// container, if any.
+func (h HostConfig) networkContainer() string {
+	if name, ok := strings.CutPrefix(h.NetworkMode, "container:"); ok {
+		return name
+	}
+	return ""
+}
+
+// Types of the mounts supported by the daemon.
+const (
+	MountBind   = "bind"
+	MountVolume = "volume"
+	MountTmpfs  = "tmpfs"
+)
+
+// Mount is a mount of a container. Source is a path on the host for bind
+// mounts and a volume name for volume mounts, in which case an empty source
+// creates an anonymous volume. tmpfs mounts have no source.
+type Mount struct {
+	Type     string
+	Source   string `json:",omitempty"`
+	Target   string
+	ReadOnly bool `json:",omitempty"`
+}
+
+func (m Mount) validate() error {
+	switch m.Type {
+	case MountBind:
+		if m.Source == "" {
+			return fmt.Errorf("invalid mount: bind mount of %q has no source", m.Target)
+		}
+	case MountVolume:
+	case MountTmpfs:
+		if m.Source != "" {
+			return fmt.Errorf("invalid mount: tmpfs mount of %q has a source", m.Target)
+		}
+	default:
+		return fmt.Errorf("invalid mount type %q", m.Type)
+	}
+	if !strings.HasPrefix(m.Target, "/") {
+		return fmt.Errorf("invalid mount: target %q is not an absolute path", m.Target)
+	}
+	return nil
+}
+
+// validateBind checks a bind in the "source:target[:options]" form. Options
+// are comma separated.
+func validateBind(bind string) error {
+	parts := strings.Split(bind, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return fmt.Errorf("invalid bind %q: expected source:target[:options]", bind)
+	}
+	if !strings.HasPrefix(parts[1], "/") {
+		return fmt.Errorf("invalid bind %q: target is not an absolute path", bind)
+	}
+	if len(parts) == 3 {
+		for _, opt := range strings.Split(parts[2], ",") {
+			switch opt {
+			case "ro", "rw", "z", "Z", "nocopy",
+				"private", "rprivate", "shared", "rshared", "slave", "rslave":
+			default:
+				return fmt.Errorf("invalid bind %q: unknown option %q", bind, opt)
+			}
+		}
+	}
+	return nil
+}
+
+// Names of the restart policies supported by the daemon.
+const (
+	RestartNo            = "no"
+	RestartAlways        = "always"
+	RestartOnFailure     = "on-failure"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// RestartPolicy tells the daemon when to restart a container that exited.
+// MaximumRetryCount only applies to the "on-failure" policy.
+type RestartPolicy struct {
+	Name              string
+	MaximumRetryCount int
+}
+
+func (p RestartPolicy) validate() error {
+	switch p.Name {
+	case "", RestartNo, RestartAlways, RestartUnlessStopped:
+		if p.MaximumRetryCount != 0 {
+			return fmt.Errorf("invalid restart policy: maximum retry count only applies to %q", RestartOnFailure)
+		}
+	case RestartOnFailure:
+		if p.MaximumRetryCount < 0 {
+			return fmt.Errorf("invalid restart policy: negative maximum retry count %d", p.MaximumRetryCount)
+		}
+	default:
+		return fmt.Errorf("invalid restart policy: %q", p.Name)
+	}
+	return nil
+}
+
+// ParsePortSpec parses a port specification in the form of the -p flag of
+// docker run, "[[ip:]hostPort:]containerPort[/protocol]", e.g. "8080:80/tcp"
+// or "[::1]:8080:80". It returns the container port key as used by
+// ExposedPorts and PortBindings, e.g. "80/tcp", and the host binding. The
+// protocol defaults to tcp and an empty or zero host port lets the daemon
+// pick one.
+func ParsePortSpec(spec string) (string, Binding, error) {
+	var (
+		binding Binding
+		proto   = "tcp"
+		rest    = spec
+	)
+
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		proto, rest = rest[i+1:], rest[:i]
+		if proto != "tcp" && proto != "udp" && proto != "sctp" {
+			return "", binding, fmt.Errorf("invalid protocol in port spec %q", spec)
+		}
+	}
+
+	// IPv6 addresses are bracketed since they contain colons themselves.
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]:")
+		if end < 0 {
+			return "", binding, fmt.Errorf("invalid host IP in port spec %q", spec)
+		}
+		binding.HostIp, rest = rest[1:end], rest[end+2:]
+		if ip := net.ParseIP(binding.HostIp); ip == nil || ip.To4() != nil {
+			return "", binding, fmt.Errorf("invalid host IP in port spec %q", spec)
+		}
+		if !strings.Contains(rest, ":") {
+			return "", binding, fmt.Errorf("invalid port spec %q", spec)
+		}
+	}
+
+	var containerPort string
+	parts := strings.Split(rest, ":")
+	switch len(parts) {
+	case 1:
+		containerPort = parts[0]
+	case 2:
+		binding.HostPort, containerPort = parts[0], parts[1]
+	case 3:
+		if binding.HostIp != "" {
+			return "", binding, fmt.Errorf("invalid port spec %q", spec)
+		}
+		binding.HostIp, binding.HostPort, containerPort = parts[0], parts[1], parts[2]
+		if binding.HostIp != "" && net.ParseIP(binding.HostIp) == nil {
+			return "", binding, fmt.Errorf("invalid host IP in port spec %q", spec)
+		}
+	default:
+		return "", binding, fmt.Errorf("invalid port spec %q", spec)
+	}
+
+	// A zero host port means any, but a container port has to be a real one.
+	if !isPort(containerPort) || portNumber(containerPort) == 0 || (binding.HostPort != "" && !isPort(binding.HostPort)) {
+		return "", binding, fmt.Errorf("invalid port in port spec %q", spec)
+	}
+
+	return containerPort + "/" + proto, binding, nil
+}
+
+func isPort(s string) bool {
+	port := portNumber(s)
+	return port >= 0 && port <= 65535
+}
+
+// portNumber returns the port in s, or -1 if it is not a number.
+func portNumber(s string) int {
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+	return port
+}
+
+// applyPorts merges host.Ports into the exposed ports and port bindings. The
+// maps are copied so that the caller's are left untouched.
+func applyPorts(cfg *ContainerConfig, host *HostConfig) error {
+	if len(host.Ports) == 0 {
+		return nil
+	}
+
+	exposed := make(map[string]struct{}, len(cfg.ExposedPorts))
+	for port := range cfg.ExposedPorts {
+		exposed[port] = struct{}{}
+	}
+	bindings := make(map[string][]Binding, len(host.PortBindings))
+	for port, b := range host.PortBindings {
+		bindings[port] = append([]Binding(nil), b...)
+	}
+
+	for _, spec := range host.Ports {
+		port, binding, err := ParsePortSpec(spec)
+		if err != nil {
+			return err
+		}
+		exposed[port] = struct{}{}
+		bindings[port] = append(bindings[port], binding)
+	}
+
+	cfg.ExposedPorts = exposed
+	host.PortBindings = bindings
+	return nil
+}
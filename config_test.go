@@ -0,0 +1,40 @@
+package docker
+
+import "testing"
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		port    string
+		binding Binding
+		wantErr bool
+	}{
+		{"80", "80/tcp", Binding{}, false},
+		{"8080:80/udp", "80/udp", Binding{HostPort: "8080"}, false},
+		{"127.0.0.1::80", "80/tcp", Binding{HostIp: "127.0.0.1"}, false},
+		{"127.0.0.1:8080:80/sctp", "80/sctp", Binding{HostIp: "127.0.0.1", HostPort: "8080"}, false},
+		{"[::1]:8080:80", "80/tcp", Binding{HostIp: "::1", HostPort: "8080"}, false},
+		{"[::1]::80/udp", "80/udp", Binding{HostIp: "::1"}, false},
+		{"0:80", "80/tcp", Binding{HostPort: "0"}, false},
+		{"[127.0.0.1]:1:2", "", Binding{}, true},
+		{"[::1]:80", "", Binding{}, true},
+		{"[::1:8080:80", "", Binding{}, true},
+		{"0", "", Binding{}, true},
+		{"8080:0", "", Binding{}, true},
+		{"65536", "", Binding{}, true},
+		{"80/foo", "", Binding{}, true},
+		{"1:2:3:4", "", Binding{}, true},
+		{"web:8080:80", "", Binding{}, true},
+		{"", "", Binding{}, true},
+	}
+	for _, tt := range tests {
+		port, binding, err := ParsePortSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePortSpec(%q): got error %v", tt.spec, err)
+			continue
+		}
+		if err == nil && (port != tt.port || binding != tt.binding) {
+			t.Errorf("ParsePortSpec(%q) = %q, %+v, want %q, %+v", tt.spec, port, binding, tt.port, tt.binding)
+		}
+	}
+}
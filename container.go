@@ -2,30 +2,149 @@ package docker
 
 import (
 	"fmt"
+	"net/url"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Container struct {
 	Id              string
 	Name            string
+	Created         time.Time
+	Path            string
+	Args            []string
+	Image           string
+	RestartCount    int
 	NetworkSettings *NetworkSettings
-	State           struct {
-		Running  bool
-		ExitCode int
-		Error    string
-	}
-	Config struct {
+	State           ContainerState
+	Config          struct {
 		Image        string
+		Hostname     string
+		User         string
+		Env          []string
+		Cmd          []string
+		Entrypoint   []string
+		WorkingDir   string
+		ExposedPorts map[string]struct{}
+		Tty          bool
+		OpenStdin    bool
 		AttachStderr bool
 		AttachStdin  bool
 		AttachStdout bool
+		Labels       map[string]string
+		StopSignal   string
+		StopTimeout  *int
+		Healthcheck  *HealthConfig
 	}
 	HostConfig struct {
 		PortBindings map[string][]Binding
 		Binds        []string
+		NetworkMode  string
 	}
+	Mounts    []MountPoint
 	Volumes   map[string]string
 	VolumesRW map[string]bool
+	// ExecIDs lists the exec instances created in the container which the
+	// daemon still knows about, see ContainerExecs.
+	ExecIDs []string
+	// SizeRw and SizeRootFs are only set by InspectContainer when asked
+	// for the size.
+	SizeRw     int64
+	SizeRootFs int64
+}
+
+// hasAddress reports whether the container got an IP address on one of its
+// networks, which is always the case for containers without networks of
+// their own.
+func (c *Container) hasAddress() bool {
+	switch mode := c.HostConfig.NetworkMode; {
+	case mode == "host", mode == "none", strings.HasPrefix(mode, "container:"):
+		return true
+	}
+	if c.NetworkSettings == nil {
+		return false
+	}
+	if c.NetworkSettings.IpAddress != "" {
+		return true
+	}
+	for _, endpoint := range c.NetworkSettings.Networks {
+		if endpoint.IPAddress != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type ContainerState struct {
+	Status     string
+	Running    bool
+	Paused     bool
+	Restarting bool
+	OOMKilled  bool
+	Dead       bool
+	Pid        int
+	ExitCode   int
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Health is only set for containers with a health check.
+	Health *Health
+}
+
+// Health is the state of a container's health check. Status is one of
+// "starting", "healthy" or "unhealthy".
+type Health struct {
+	Status        string
+	FailingStreak int
+	Log           []HealthLog
+}
+
+// HealthLog is the result of a single run of a health check.
+type HealthLog struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
+}
+
+// MountPoint is a volume or bind mount of a container as reported by the
+// daemon.
+type MountPoint struct {
+	Type        string
+	Name        string
+	Source      string
+	Destination string
+	Driver      string
+	Mode        string
+	RW          bool
+	Propagation string
+}
+
+// ContainerSummary is the shorter description of a container returned when
+// listing containers. Use FetchContainer to get the full details.
+type ContainerSummary struct {
+	Id         string
+	Names      []string
+	Image      string
+	ImageID    string
+	Command    string
+	Created    int64
+	State      string
+	Status     string
+	Ports      []Port
+	Labels     map[string]string
+	SizeRw     int64
+	SizeRootFs int64
+}
+
+// Port is a port exposed by a container, as listed in ContainerSummary.
+type Port struct {
+	IP          string
+	PrivatePort int
+	PublicPort  int
+	Type        string
 }
 
 func (container *Container) GetVolumes() (map[string]*Volume, error) {
@@ -71,3 +190,100 @@ func (container *Container) getBindMap() (map[string]*Volume, error) {
 	}
 	return volumes, nil
 }
+
+// TopResult lists the processes running in a container, as reported by ps.
+type TopResult struct {
+	Titles    []string
+	Processes [][]string
+}
+
+// UpdateConfig holds the resource limits changed by UpdateContainer. Zero
+// fields are left untouched.
+type UpdateConfig struct {
+	Memory     int64  `json:",omitempty"`
+	MemorySwap int64  `json:",omitempty"`
+	CpuShares  int64  `json:",omitempty"`
+	CpusetCpus string `json:",omitempty"`
+	NanoCpus   int64  `json:",omitempty"`
+}
+
+// Kinds of FileChange.
+const (
+	ChangeModify = iota
+	ChangeAdd
+	ChangeDelete
+)
+
+// FileChange is a path changed in a container's filesystem, see ContainerDiff.
+type FileChange struct {
+	Path string
+	Kind int
+}
+
+// CommitOptions controls the image created by CommitContainer. Config, if set,
+// overrides the container's config (Cmd, Env, ...) in the new image.
+type CommitOptions struct {
+	Repo    string
+	Tag     string
+	Comment string
+	Author  string
+	Config  map[string]interface{}
+}
+
+// ListOptions selects the containers returned by ListContainers. By default
+// only running containers are listed. Filters maps a filter name such as
+// "status" or "label" to the values to match.
+type ListOptions struct {
+	All     bool
+	Limit   int
+	Size    bool
+	Filters map[string][]string
+}
+
+func (o ListOptions) values() (url.Values, error) {
+	v := url.Values{}
+	v.Set("all", strconv.FormatBool(o.All))
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Size {
+		v.Set("size", "1")
+	}
+	if err := setFilters(v, o.Filters); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// AttachOptions selects the streams AttachContainer connects to. Logs replays
+// the output written before attaching. Demux strips the stream headers from
+// the output of containers without a TTY, merging stdout and stderr.
+type AttachOptions struct {
+	Stdin      bool
+	Stdout     bool
+	Stderr     bool
+	Logs       bool
+	DetachKeys string
+	Demux      bool
+}
+
+func (o AttachOptions) values() url.Values {
+	v := url.Values{}
+	v.Set("stream", "1")
+	if o.Stdin {
+		v.Set("stdin", "1")
+	}
+	if o.Stdout {
+		v.Set("stdout", "1")
+	}
+	if o.Stderr {
+		v.Set("stderr", "1")
+	}
+	if o.Logs {
+		v.Set("logs", "1")
+	}
+	if o.DetachKeys != "" {
+		v.Set("detachKeys", o.DetachKeys)
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package docker
+
+// DiskUsageReport is the disk space used by the daemon, in bytes, broken down
+// by kind of object as by docker system df.
+type DiskUsageReport struct {
+	// LayersSize is the size of all image layers, shared ones counted once.
+	LayersSize int64
+	Images     DiskUsage
+	Containers DiskUsage
+	Volumes    DiskUsage
+	BuildCache DiskUsage
+}
+
+// DiskUsage is the disk space used by one kind of object. Active counts the
+// objects in use, e.g. images with containers or running containers, and
+// Reclaimable is the space a prune would free.
+type DiskUsage struct {
+	Total       int
+	Active      int
+	Size        int64
+	Reclaimable int64
+}
+
+// diskUsageResponse is the response of /system/df. Sizes that were not
+// computed are -1.
+type diskUsageResponse struct {
+	LayersSize int64
+	Images     []struct {
+		Size       int64
+		SharedSize int64
+		Containers int
+	}
+	Containers []struct {
+		SizeRw int64
+		State  string
+	}
+	Volumes []struct {
+		UsageData struct {
+			Size     int64
+			RefCount int
+		}
+	}
+	BuildCache []struct {
+		Size   int64
+		InUse  bool
+		Shared bool
+	}
+}
+
+func (r *diskUsageResponse) report() *DiskUsageReport {
+	report := &DiskUsageReport{LayersSize: r.LayersSize}
+
+	// Images share layers, so only the part of an image in use which is
+	// not shared with others can be told apart from what is reclaimable.
+	var used int64
+	report.Images = DiskUsage{Total: len(r.Images), Size: r.LayersSize}
+	for _, image := range r.Images {
+		if image.Containers == 0 {
+			continue
+		}
+		report.Images.Active++
+		if image.Size >= 0 && image.SharedSize >= 0 {
+			used += image.Size - image.SharedSize
+		}
+	}
+	report.Images.Reclaimable = r.LayersSize - used
+
+	report.Containers.Total = len(r.Containers)
+	for _, c := range r.Containers {
+		if c.SizeRw < 0 {
+			continue
+		}
+		report.Containers.Size += c.SizeRw
+		switch c.State {
+		case "running", "paused", "restarting":
+			report.Containers.Active++
+		default:
+			report.Containers.Reclaimable += c.SizeRw
+		}
+	}
+
+	report.Volumes.Total = len(r.Volumes)
+	for _, v := range r.Volumes {
+		if v.UsageData.RefCount > 0 {
+			report.Volumes.Active++
+		}
+		if v.UsageData.Size < 0 {
+			continue
+		}
+		report.Volumes.Size += v.UsageData.Size
+		if v.UsageData.RefCount == 0 {
+			report.Volumes.Reclaimable += v.UsageData.Size
+		}
+	}
+
+	report.BuildCache.Total = len(r.BuildCache)
+	for _, b := range r.BuildCache {
+		report.BuildCache.Size += b.Size
+		if b.InUse {
+			report.BuildCache.Active++
+		} else if !b.Shared {
+			report.BuildCache.Reclaimable += b.Size
+		}
+	}
+	return report
+}
@@ -0,0 +1,89 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// DefaultHost is the address of the daemon on the current platform when
+// DOCKER_HOST is not set. It is empty on Windows, where the daemon listens on
+// a named pipe which the client cannot dial by itself; use WithDialer there.
+var DefaultHost = defaultHost()
+
+func defaultHost() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	return "unix:///var/run/docker.sock"
+}
+
+// NewClientFromEnv returns a client configured the same way as the docker CLI:
+// DOCKER_HOST selects the daemon (DefaultHost if unset), DOCKER_TLS_VERIFY
+// turns on TLS with the ca.pem, cert.pem and key.pem found in DOCKER_CERT_PATH
+// (~/.docker if unset) and DOCKER_API_VERSION pins the API version. opts are
+// applied after the settings from the environment.
+func NewClientFromEnv(opts ...ClientOption) (Docker, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = DefaultHost
+	}
+	if host == "" {
+		return nil, fmt.Errorf("DOCKER_HOST is not set and there is no default daemon address on %s", runtime.GOOS)
+	}
+
+	envOpts := []ClientOption{WithVersion(os.Getenv("DOCKER_API_VERSION"))}
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		certPath := os.Getenv("DOCKER_CERT_PATH")
+		if certPath == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			certPath = filepath.Join(home, ".docker")
+		}
+
+		config, err := NewTLSConfig(
+			filepath.Join(certPath, "ca.pem"),
+			filepath.Join(certPath, "cert.pem"),
+			filepath.Join(certPath, "key.pem"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		envOpts = append(envOpts, WithTLSConfig(config))
+	}
+
+	return NewClient(host, append(envOpts, opts...)...)
+}
+
+// NewTLSConfig loads a config which verifies the daemon against the CA in
+// caFile and presents the client certificate in certFile and keyFile. Either
+// part is skipped if its file names are empty.
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
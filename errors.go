@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound is matched by errors returned when the daemon does not know
+	// about the requested container, image, volume or network.
+	ErrNotFound = errors.New("no such object")
+	// ErrConflict is matched by errors returned when the request conflicts
+	// with the current state, e.g. a name that is already in use.
+	ErrConflict = errors.New("conflict")
+	// ErrNotRunning is matched by errors from operations that need a running
+	// container.
+	ErrNotRunning = errors.New("container is not running")
+	// ErrNotPaused is matched by errors from UnpauseContainer when the
+	// container is not paused.
+	ErrNotPaused = errors.New("container is not paused")
+	// ErrAlreadyPaused is matched by errors from PauseContainer when the
+	// container is already paused.
+	ErrAlreadyPaused = errors.New("container is already paused")
+	// ErrAlreadyStarted is matched by errors from StartContainer when the
+	// container was already running, so nothing was done.
+	ErrAlreadyStarted = errors.New("container already started")
+	// ErrStillRunning is matched by errors from WaitForExit and RunAndWait
+	// when the container was still running once the timeout expired.
+	ErrStillRunning = errors.New("container is still running")
+	// ErrIdleTimeout is returned when reading a stream with an idle timeout
+	// set, such as EventOptions.IdleTimeout, after nothing was received for
+	// that long.
+	ErrIdleTimeout = errors.New("stream idle timeout")
+)
+
+// APIError is returned when the daemon answers with a status code that is not
+// considered a success. Message holds the explanation sent by the daemon, if
+// any.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+// newAPIError reads the explanation of the failure from the response body.
+// Current daemons send {"message": "..."}, older ones send plain text.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return apiErr
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(buf, &body); err == nil {
+		apiErr.Message = body.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(buf))
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("invalid HTTP request %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	return msg
+}
+
+// Is lets callers use errors.Is with ErrNotFound and ErrConflict.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	}
+	return false
+}
+
+// isStatus reports whether err was caused by the daemon answering with code.
+func isStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
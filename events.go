@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+type Event struct {
+	// ContainerId and Status are only set for container events, they mirror
+	// Actor.ID and Action for older consumers.
+	ContainerId string `json:"id"`
+	Status      string `json:"status"`
+	From        string `json:"from"`
+
+	Type     string `json:"Type"`
+	Action   string `json:"Action"`
+	Actor    EventActor
+	Time     int64 `json:"time"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// EventActor is the object an event is about, e.g. a container, network or
+// volume.
+type EventActor struct {
+	ID         string
+	Attributes map[string]string
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type event Event
+	if err := json.Unmarshal(data, (*event)(e)); err != nil {
+		return err
+	}
+
+	// Older daemons only send id/status, with the image in from for
+	// container events, newer ones may only send the Type/Action/Actor form.
+	if e.Type == "" && e.ContainerId != "" {
+		e.Type = "image"
+		if e.From != "" {
+			e.Type = "container"
+		}
+	}
+	if e.Actor.ID == "" {
+		e.Actor.ID = e.ContainerId
+	}
+	if e.Action == "" {
+		e.Action = e.Status
+	}
+	if e.Type == "container" {
+		e.ContainerId, e.Status = e.Actor.ID, e.Action
+	} else {
+		e.ContainerId, e.Status = "", ""
+	}
+	return nil
+}
+
+// readEvents decodes the events stream in r and hands each event to emit.
+// Reading stops early if emit returns false. Whitespace sent to keep the
+// connection alive, null values and objects which are not events are
+// skipped, while an error object sent by the daemon ends the stream with
+// that error.
+func readEvents(r io.Reader, emit func(*Event) bool) error {
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var msg struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(raw, &msg) == nil && (msg.Error != "" || msg.Message != "") {
+			if msg.Error == "" {
+				msg.Error = msg.Message
+			}
+			return errors.New(msg.Error)
+		}
+
+		var event *Event
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return err
+		}
+		// Every event has a Type once decoded, anything else such as a
+		// trailing status object is not one.
+		if event == nil || event.Type == "" {
+			continue
+		}
+		if !emit(event) {
+			return nil
+		}
+	}
+}
+
+// timestamp returns when the event happened, at the best precision sent by the
+// daemon.
+func (e *Event) timestamp() time.Time {
+	if e.TimeNano != 0 {
+		return time.Unix(0, e.TimeNano)
+	}
+	return time.Unix(e.Time, 0)
+}
+
+// Bounds of the delay between two reconnection attempts of
+// GetEventsReconnect.
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+	// reconnectIdleTimeout is the EventOptions.IdleTimeout used by
+	// GetEventsReconnect. Hitting it on a quiet daemon only costs a
+	// reconnect.
+	reconnectIdleTimeout = 5 * time.Minute
+)
+
+// EventOptions narrows down the events returned by GetEventsFiltered. Zero
+// values are left out of the request.
+type EventOptions struct {
+	Since time.Time
+	Until time.Time
+	// Filters maps a filter name such as "type", "event" or "label" to the
+	// values to match, e.g. {"type": {"container"}, "event": {"die"}}.
+	Filters map[string][]string
+
+	// BufferSize is the capacity of the event channel, 100 if zero. Once it
+	// is full, reading from the daemon waits for the consumer to catch up
+	// unless DropWhenFull is set, in which case new events are discarded
+	// and counted in Dropped, if set.
+	BufferSize   int
+	DropWhenFull bool
+	Dropped      *atomic.Uint64
+
+	// IdleTimeout ends the stream with ErrIdleTimeout once nothing was
+	// received for that long, so that a connection which silently died is
+	// noticed. Zero waits forever. The daemon does not send anything while
+	// no event happens, so it should be well above the expected quiet times.
+	IdleTimeout time.Duration
+}
+
+func (o EventOptions) bufferSize() int {
+	if o.BufferSize <= 0 {
+		return 100
+	}
+	return o.BufferSize
+}
+
+func (o EventOptions) values() (url.Values, error) {
+	v := url.Values{}
+	if !o.Since.IsZero() {
+		v.Set("since", formatTimestamp(o.Since))
+	}
+	if !o.Until.IsZero() {
+		v.Set("until", formatTimestamp(o.Until))
+	}
+	if err := setFilters(v, o.Filters); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// formatTimestamp formats t the way the daemon expects timestamps in query
+// parameters: seconds since the epoch with an optional fractional part.
+func formatTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
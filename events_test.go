@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestReadEvents(t *testing.T) {
+	// Recorded from a daemon with heartbeats in between, ending with the
+	// status object some proxies send when closing the stream.
+	const stream = `{"status":"start","id":"4a2f9c","from":"busybox","Type":"container","Action":"start","Actor":{"ID":"4a2f9c","Attributes":{"image":"busybox","name":"web"}},"scope":"local","time":1700000000,"timeNano":1700000000123456789}
+
+null
+{"Type":"network","Action":"connect","Actor":{"ID":"8d1e07","Attributes":{"container":"4a2f9c","name":"bridge","type":"bridge"}},"scope":"local","time":1700000001,"timeNano":1700000001000000000}
+  
+{"status":"die","id":"4a2f9c","from":"busybox","time":1700000002}
+{"status":"stream closed"}
+`
+
+	var events []*Event
+	err := readEvents(strings.NewReader(stream), func(e *Event) bool {
+		events = append(events, e)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("readEvents: %v", err)
+	}
+
+	want := []struct {
+		typ, action, actor, status string
+	}{
+		{"container", "start", "4a2f9c", "start"},
+		{"network", "connect", "8d1e07", ""},
+		{"container", "die", "4a2f9c", "die"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Type != w.typ || e.Action != w.action || e.Actor.ID != w.actor || e.Status != w.status {
+			t.Errorf("event %d: got %s/%s/%s/%s, want %s/%s/%s/%s", i,
+				e.Type, e.Action, e.Actor.ID, e.Status, w.typ, w.action, w.actor, w.status)
+		}
+	}
+	if got := events[0].timestamp().UnixNano(); got != 1700000000123456789 {
+		t.Errorf("timestamp: got %d", got)
+	}
+}
+
+func TestReadEventsError(t *testing.T) {
+	const stream = `{"Type":"container","Action":"start","Actor":{"ID":"4a2f9c"},"time":1700000000}
+{"message":"context canceled"}
+{"Type":"container","Action":"stop","Actor":{"ID":"4a2f9c"},"time":1700000001}
+`
+
+	var n int
+	err := readEvents(strings.NewReader(stream), func(*Event) bool {
+		n++
+		return true
+	})
+	if err == nil || err.Error() != "context canceled" {
+		t.Fatalf("got error %v, want context canceled", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d events before the error, want 1", n)
+	}
+}
+
+func TestReadEventsStop(t *testing.T) {
+	const stream = `{"Type":"container","Action":"start","Actor":{"ID":"a"}}
+{"Type":"container","Action":"start","Actor":{"ID":"b"}}
+`
+
+	var n int
+	err := readEvents(strings.NewReader(stream), func(*Event) bool {
+		n++
+		return false
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("got %d events and error %v, want 1 and none", n, err)
+	}
+}
+
+func TestEventUnmarshal(t *testing.T) {
+	tests := []struct {
+		json                           string
+		typ, action, actor, id, status string
+	}{
+		// Before API 1.22.
+		{`{"status":"create","id":"4a2f9c","from":"busybox","time":1}`, "container", "create", "4a2f9c", "4a2f9c", "create"},
+		{`{"status":"untag","id":"sha256:abc","time":1}`, "image", "untag", "sha256:abc", "", ""},
+		// Before API 1.44, which still sent the legacy fields.
+		{`{"status":"pull","id":"alpine:latest","Type":"image","Action":"pull","Actor":{"ID":"alpine:latest"},"time":1}`, "image", "pull", "alpine:latest", "", ""},
+		{`{"status":"start","id":"4a2f9c","from":"busybox","Type":"container","Action":"start","Actor":{"ID":"4a2f9c"},"time":1}`, "container", "start", "4a2f9c", "4a2f9c", "start"},
+		// Current daemons.
+		{`{"Type":"container","Action":"die","Actor":{"ID":"4a2f9c"},"time":1}`, "container", "die", "4a2f9c", "4a2f9c", "die"},
+		{`{"Type":"volume","Action":"create","Actor":{"ID":"data"},"time":1}`, "volume", "create", "data", "", ""},
+	}
+	for _, tt := range tests {
+		var e Event
+		if err := json.Unmarshal([]byte(tt.json), &e); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if e.Type != tt.typ || e.Action != tt.action || e.Actor.ID != tt.actor || e.ContainerId != tt.id || e.Status != tt.status {
+			t.Errorf("%s: got %s/%s/%s id=%q status=%q", tt.json, e.Type, e.Action, e.Actor.ID, e.ContainerId, e.Status)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package docker
+
+// ExecConfig configures a command run in a container with ExecCreate.
+type ExecConfig struct {
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	Tty          bool
+	Env          []string
+	User         string
+}
+
+// ExecInspectResult is the state of an exec instance as reported by
+// ExecInspect.
+type ExecInspectResult struct {
+	ID          string
+	ContainerID string
+	Running     bool
+	// ExitCode is only meaningful once Running is false.
+	ExitCode      int
+	Pid           int
+	ProcessConfig ExecProcessConfig
+}
+
+// ExecProcessConfig is the process started by an exec instance.
+type ExecProcessConfig struct {
+	Entrypoint string   `json:"entrypoint"`
+	Arguments  []string `json:"arguments"`
+	Tty        bool     `json:"tty"`
+	User       string   `json:"user"`
+	Privileged bool     `json:"privileged"`
+}
@@ -0,0 +1,66 @@
+package docker
+
+type Image struct {
+	Id          string
+	RepoTags    []string
+	Created     int64
+	Size        int64
+	VirtualSize int64
+}
+
+type ImageInfo struct {
+	Id      string
+	Parent  string
+	Created string
+	Config  struct {
+		Env          []string
+		Cmd          []string
+		Entrypoint   []string
+		ExposedPorts map[string]struct{}
+		Labels       map[string]string
+	}
+	Architecture string
+	Os           string
+	Size         int64
+	VirtualSize  int64
+}
+
+// ImageDeleteResponse describes a single reference untagged or layer deleted
+// by RemoveImage. Only one of the fields is set.
+type ImageDeleteResponse struct {
+	Untagged string
+	Deleted  string
+}
+
+// PullStatus reports the progress of a single layer of an image pull. Id is
+// empty for messages about the pull as a whole.
+type PullStatus struct {
+	Id       string
+	Status   string
+	Progress struct {
+		Current int64
+		Total   int64
+	}
+}
+
+// HistoryLayer is a layer of an image, see ImageHistory.
+type HistoryLayer struct {
+	Id        string
+	Created   int64
+	CreatedBy string
+	Size      int64
+	Comment   string
+	Tags      []string
+}
+
+// PushStatus reports the progress of a single layer of an image push.
+type PushStatus = PullStatus
+
+// SearchResult is an image found in the registry by SearchImages.
+type SearchResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	StarCount   int    `json:"star_count"`
+	IsOfficial  bool   `json:"is_official"`
+	IsAutomated bool   `json:"is_automated"`
+}
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// LogOptions selects the logs returned by ContainerLogsRaw and
+// ContainerLogsOpts.
+type LogOptions struct {
+	Follow     bool
+	Stdout     bool
+	Stderr     bool
+	Timestamps bool
+	// Tail is the number of lines to return from the end of the logs, all
+	// of them if empty or "all".
+	Tail  string
+	Since time.Time
+	Until time.Time
+	// Demux strips the stream headers from the logs of containers without
+	// a TTY, merging stdout and stderr.
+	Demux bool
+	// SplitTimestamps asks for timestamps like Timestamps and has
+	// ContainerLogsOpts parse them into LogLine.Time rather than leave them
+	// at the start of the line.
+	SplitTimestamps bool
+	// IdleTimeout ends a followed stream with ErrIdleTimeout once nothing
+	// was received for that long, see EventOptions.IdleTimeout.
+	IdleTimeout time.Duration
+}
+
+// newLogOptions converts the positional arguments of ContainerLogs and
+// ContainerLogLines. A tail of -1 means all lines and zero since and until
+// are unset.
+func newLogOptions(follow, stdout, stderr, timestamps bool, tail int, since, until int64) LogOptions {
+	opts := LogOptions{
+		Follow:     follow,
+		Stdout:     stdout,
+		Stderr:     stderr,
+		Timestamps: timestamps,
+		Tail:       strconv.Itoa(tail),
+	}
+	if tail == -1 {
+		opts.Tail = "all"
+	}
+	if since != 0 {
+		opts.Since = time.Unix(since, 0)
+	}
+	if until != 0 {
+		opts.Until = time.Unix(until, 0)
+	}
+	return opts
+}
+
+func (o LogOptions) values() url.Values {
+	v := url.Values{}
+	v.Set("follow", strconv.FormatBool(o.Follow))
+	v.Set("stdout", strconv.FormatBool(o.Stdout))
+	v.Set("stderr", strconv.FormatBool(o.Stderr))
+	v.Set("timestamps", strconv.FormatBool(o.Timestamps || o.SplitTimestamps))
+	if o.Tail == "" {
+		v.Set("tail", "all")
+	} else {
+		v.Set("tail", o.Tail)
+	}
+	if !o.Since.IsZero() {
+		v.Set("since", formatTimestamp(o.Since))
+	}
+	if !o.Until.IsZero() {
+		v.Set("until", formatTimestamp(o.Until))
+	}
+	return v
+}
@@ -0,0 +1,40 @@
+package docker
+
+// Network is a network managed by the daemon.
+type Network struct {
+	Name       string
+	Id         string
+	Driver     string
+	Scope      string
+	Internal   bool
+	IPAM       IPAM
+	Containers map[string]struct {
+		Name        string
+		EndpointID  string
+		MacAddress  string
+		IPv4Address string
+		IPv6Address string
+	}
+	Labels map[string]string
+}
+
+// NetworkOptions describes a network to be created with CreateNetwork.
+type NetworkOptions struct {
+	Name     string
+	Driver   string            `json:",omitempty"`
+	Internal bool              `json:",omitempty"`
+	IPAM     *IPAM             `json:",omitempty"`
+	Labels   map[string]string `json:",omitempty"`
+}
+
+// IPAM is the IP address management configuration of a network.
+type IPAM struct {
+	Driver string       `json:",omitempty"`
+	Config []IPAMConfig `json:",omitempty"`
+}
+
+type IPAMConfig struct {
+	Subnet  string `json:",omitempty"`
+	IPRange string `json:",omitempty"`
+	Gateway string `json:",omitempty"`
+}
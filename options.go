@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClientOption configures a client created by NewClient.
+type ClientOption func(*dockerClient)
+
+// WithTLSConfig wraps every connection to the daemon in TLS using config. Set
+// config.RootCAs to verify the daemon against a private CA and
+// config.Certificates to present a client certificate.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(d *dockerClient) {
+		d.tlsConfig = config
+	}
+}
+
+// WithDialer opens the connections with dial rather than dialing the path
+// directly. The network and address passed to dial are derived from the path
+// as by ParseURL. This is useful to go through a proxy or to point the client
+// at a test server.
+func WithDialer(dial DialFunc) ClientOption {
+	return func(d *dockerClient) {
+		d.dial = dial
+	}
+}
+
+// WithTransport sends the requests through transport, which is handed URLs
+// whose host is derived from the path as by ParseURL. Recording or faking
+// requests in tests is a matter of wrapping or replacing the transport; see
+// also NewTestClient. The TLS config and dialer are not used by a custom
+// transport.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(d *dockerClient) {
+		d.client.Transport = transport
+	}
+}
+
+// WithVersion pins every request to the given API version, e.g. "v1.41",
+// instead of using the daemon's default. An empty version is ignored.
+func WithVersion(version string) ClientOption {
+	return func(d *dockerClient) {
+		if version != "" {
+			d.version = "v" + strings.TrimPrefix(version, "v")
+		}
+	}
+}
+
+// WithTimeout gives up on requests that take longer than timeout. Streaming
+// and long running requests such as events, logs, stats, waits, stops,
+// restarts, pulls, builds and archive transfers are not bound by it.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(d *dockerClient) {
+		d.timeout = timeout
+	}
+}
+
+// WithRetryPolicy sets how requests failing for transient reasons are
+// retried, see SetRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(d *dockerClient) {
+		d.retry = policy
+	}
+}
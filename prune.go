@@ -0,0 +1,29 @@
+package docker
+
+// PruneReport lists what was removed by one of the prune methods and how much
+// disk space was freed, in bytes.
+type PruneReport struct {
+	Deleted        []string
+	SpaceReclaimed uint64
+}
+
+// pruneResponse covers the responses of all prune endpoints, each of which
+// only sets the list matching its kind of object.
+type pruneResponse struct {
+	ContainersDeleted []string
+	ImagesDeleted     []ImageDeleteResponse
+	VolumesDeleted    []string
+	SpaceReclaimed    uint64
+}
+
+func (r *pruneResponse) report() *PruneReport {
+	report := &PruneReport{SpaceReclaimed: r.SpaceReclaimed}
+	report.Deleted = append(report.Deleted, r.ContainersDeleted...)
+	report.Deleted = append(report.Deleted, r.VolumesDeleted...)
+	for _, image := range r.ImagesDeleted {
+		if image.Deleted != "" {
+			report.Deleted = append(report.Deleted, image.Deleted)
+		}
+	}
+	return report
+}
@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RetryPolicy controls how requests failing for transient reasons are retried.
+// Connection failures are retried for every request; server errors and broken
+// connections only for idempotent GET, HEAD and DELETE requests, so that e.g.
+// a container is never created twice. Requests with a body that cannot be
+// replayed, such as a build context, are never retried.
+//
+// The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for every
+	// retry after that.
+	BaseDelay time.Duration
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	return p.BaseDelay << uint(attempt-1)
+}
+
+func (p RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	// Lacking permission on the socket won't fix itself.
+	if errors.Is(err, os.ErrPermission) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	switch req.Method {
+	case "GET", "HEAD", "DELETE":
+	default:
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
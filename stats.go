@@ -0,0 +1,42 @@
+package docker
+
+import "time"
+
+// Stats is a single resource usage sample of a container.
+type Stats struct {
+	Read        time.Time               `json:"read"`
+	CPUStats    CPUStats                `json:"cpu_stats"`
+	PreCPUStats CPUStats                `json:"precpu_stats"`
+	MemoryStats MemoryStats             `json:"memory_stats"`
+	Networks    map[string]NetworkStats `json:"networks"`
+}
+
+type CPUStats struct {
+	CPUUsage struct {
+		TotalUsage        uint64   `json:"total_usage"`
+		PercpuUsage       []uint64 `json:"percpu_usage"`
+		UsageInKernelmode uint64   `json:"usage_in_kernelmode"`
+		UsageInUsermode   uint64   `json:"usage_in_usermode"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+type MemoryStats struct {
+	Usage    uint64            `json:"usage"`
+	MaxUsage uint64            `json:"max_usage"`
+	Limit    uint64            `json:"limit"`
+	Stats    map[string]uint64 `json:"stats"`
+}
+
+// NetworkStats holds the counters of a single network interface.
+type NetworkStats struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
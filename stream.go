@@ -0,0 +1,318 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// frameHeaderLen is the size of the header the daemon prefixes to every
+	// frame of a multiplexed (non-TTY) stdout/stderr stream.
+	frameHeaderLen = 8
+
+	streamStdin  = 0
+	streamStdout = 1
+	streamStderr = 2
+)
+
+// LogLine is a single line of container output along with the stream it was
+// written to, either "stdout" or "stderr". Time is only set when the logs were
+// read with LogOptions.SplitTimestamps, in which case Line holds the message
+// without its timestamp.
+type LogLine struct {
+	Time   time.Time
+	Stream string
+	Line   string
+}
+
+// isMultiplexed reports whether header looks like the start of a multiplexed
+// stream frame. TTY output is sent raw and will not match.
+func isMultiplexed(header []byte) bool {
+	return len(header) >= frameHeaderLen &&
+		header[0] <= streamStderr &&
+		header[1] == 0 && header[2] == 0 && header[3] == 0
+}
+
+func streamName(b byte) string {
+	if b == streamStderr {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// readFrame reads a single frame from a multiplexed stream, returning the name
+// of the stream it belongs to and its payload.
+func readFrame(r io.Reader) (string, []byte, error) {
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", nil, fmt.Errorf("truncated stream frame header")
+		}
+		return "", nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[4:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", nil, err
+	}
+	return streamName(header[0]), payload, nil
+}
+
+// Demux copies a multiplexed stdout/stderr stream from src into stdout and
+// stderr. If src is not multiplexed, as is the case for TTY containers,
+// everything is copied to stdout.
+func Demux(stdout, stderr io.Writer, src io.Reader) error {
+	br := bufio.NewReader(src)
+	if header, _ := br.Peek(frameHeaderLen); !isMultiplexed(header) {
+		_, err := io.Copy(stdout, br)
+		return err
+	}
+
+	for {
+		stream, payload, err := readFrame(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		w := stdout
+		if stream == "stderr" {
+			w = stderr
+		}
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+	}
+}
+
+// newDemuxReader returns a reader of the payload of the stream in r, with the
+// frame headers stripped if the stream is multiplexed. Nothing is read from r
+// until the first call to Read.
+func newDemuxReader(r io.Reader) io.Reader {
+	return &demuxReader{r: bufio.NewReader(r)}
+}
+
+// demuxReader strips the frame headers from a multiplexed stream, merging
+// stdout and stderr in the order they were written. Streams that turn out not
+// to be multiplexed are passed through as is.
+type demuxReader struct {
+	r       *bufio.Reader
+	buf     []byte
+	checked bool
+	raw     bool
+}
+
+func (d *demuxReader) Read(p []byte) (int, error) {
+	if !d.checked {
+		header, _ := d.r.Peek(frameHeaderLen)
+		d.raw = len(header) > 0 && !isMultiplexed(header)
+		d.checked = true
+	}
+	if d.raw {
+		return d.r.Read(p)
+	}
+	for len(d.buf) == 0 {
+		_, payload, err := readFrame(d.r)
+		if err != nil {
+			return 0, err
+		}
+		d.buf = payload
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// idleReader closes the stream it wraps once no data was read from it for
+// timeout, making pending and later reads fail with ErrIdleTimeout.
+type idleReader struct {
+	rc      io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+func newIdleReader(rc io.ReadCloser, timeout time.Duration) io.ReadCloser {
+	r := &idleReader{rc: rc, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		r.expired.Store(true)
+		rc.Close()
+	})
+	return r
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if r.expired.Load() {
+		return n, ErrIdleTimeout
+	}
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	return r.rc.Close()
+}
+
+// readLogLines splits the (possibly multiplexed) stream in r into lines and
+// hands each of them to emit. Reading stops early if emit returns false.
+// Unlike bufio.Scanner there is no limit on the length of a line, so a single
+// huge JSON log entry is delivered whole rather than ending the stream.
+func readLogLines(r io.Reader, emit func(*LogLine) bool) error {
+	br := bufio.NewReader(r)
+	if header, _ := br.Peek(frameHeaderLen); !isMultiplexed(header) {
+		for {
+			line, err := br.ReadString('\n')
+			if len(line) > 0 && !emit(&LogLine{Stream: "stdout", Line: trimNewline(line)}) {
+				return nil
+			}
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+
+	// Frames don't necessarily end on a line boundary, so keep whatever is
+	// left over per stream until the rest of the line arrives.
+	pending := map[string][]byte{}
+	for {
+		stream, payload, err := readFrame(br)
+		if err != nil {
+			for _, s := range []string{"stdout", "stderr"} {
+				if len(pending[s]) > 0 && !emit(&LogLine{Stream: s, Line: string(pending[s])}) {
+					return nil
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		buf := append(pending[stream], payload...)
+		for {
+			i := bytes.IndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+			if !emit(&LogLine{Stream: stream, Line: trimNewline(string(buf[:i+1]))}) {
+				return nil
+			}
+			buf = buf[i+1:]
+		}
+		pending[stream] = append([]byte(nil), buf...)
+	}
+}
+
+// splitTimestamp splits the RFC 3339 timestamp the daemon prefixes log lines
+// with from the message. Lines without a valid timestamp are returned whole
+// with a zero time.
+func splitTimestamp(line string) (time.Time, string) {
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		i = len(line)
+	}
+	t, err := time.Parse(time.RFC3339Nano, line[:i])
+	if err != nil {
+		return time.Time{}, line
+	}
+	if i < len(line) {
+		i++
+	}
+	return t, line[i:]
+}
+
+func trimNewline(s string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+}
+
+// progressMessage is a single JSON object from the progress stream sent by
+// pulls, pushes and builds. The daemon answers 200 before the operation is done
+// and reports failures in the Error field.
+type progressMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Stream string `json:"stream"`
+	Error  string `json:"error"`
+}
+
+// streamProgress decodes the progress stream in body into statuses until it
+// ends or ctx is cancelled. body is closed once done.
+func streamProgress(ctx context.Context, body io.ReadCloser) (<-chan PullStatus, <-chan error) {
+	var (
+		statuses = make(chan PullStatus, 100)
+		errChan  = make(chan error, 1)
+	)
+	go func() {
+		defer close(errChan)
+		defer close(statuses)
+		defer body.Close()
+
+		dec := json.NewDecoder(body)
+		for {
+			var m progressMessage
+			if err := dec.Decode(&m); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					errChan <- err
+				}
+				return
+			}
+			if m.Error != "" {
+				errChan <- errors.New(m.Error)
+				return
+			}
+
+			status := PullStatus{Id: m.ID, Status: m.Status}
+			status.Progress.Current = m.ProgressDetail.Current
+			status.Progress.Total = m.ProgressDetail.Total
+			select {
+			case statuses <- status:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return statuses, errChan
+}
+
+// drainProgress reads the progress stream in r until EOF and returns the first
+// error reported by the daemon.
+func drainProgress(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var m progressMessage
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if m.Error != "" {
+			return errors.New(m.Error)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package docker
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// NewTestClient returns a client whose requests are served by handler rather
+// than a daemon, for testing code built on this package. The handler sees the
+// requests as sent to the daemon, API version prefix included, and may stream
+// or hijack the connection like the daemon does. opts such as WithVersion
+// apply as with NewClient, while the dialer, transport and TLS config they set
+// are ignored. Close the client to stop the server behind it.
+func NewTestClient(handler http.Handler, opts ...ClientOption) (Docker, error) {
+	srv := httptest.NewServer(handler)
+
+	d := newDockerClient("tcp://" + srv.Listener.Addr().String())
+	for _, opt := range opts {
+		opt(d)
+	}
+	// The connections must go to srv.
+	d.dial, d.tlsConfig = nil, nil
+	d.client.Transport = &http.Transport{DialContext: d.dialContext}
+	d.cleanup = func() {
+		// Streaming handlers would otherwise keep Close waiting.
+		srv.CloseClientConnections()
+		srv.Close()
+	}
+	return d, nil
+}
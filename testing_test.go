@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewTestClient(t *testing.T) {
+	var method, uri, body string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, uri, body = r.Method, r.URL.RequestURI(), string(b)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	resp, err := client.Do(context.Background(), "POST", "/networks/prune?filters=%7B%7D", map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+
+	if method != "POST" || uri != "/networks/prune?filters=%7B%7D" || body != `{"a":"b"}` {
+		t.Errorf("handler saw %s %s %q", method, uri, body)
+	}
+}
+
+func TestNewTestClientCloseStreaming(t *testing.T) {
+	client, err := NewTestClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	if err != nil {
+		t.Fatalf("NewTestClient: %v", err)
+	}
+
+	events, _, err := client.GetEvents(context.Background())
+	if err != nil {
+		t.Fatalf("GetEvents: %v", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		client.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on a streaming handler")
+	}
+	for range events {
+	}
+}
+
+func TestNewTestClientOptions(t *testing.T) {
+	var path string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	},
+		WithVersion("1.44"),
+		WithTLSConfig(&tls.Config{}),
+		WithDialer(func(string, string) (net.Conn, error) { return nil, errors.New("not the test server") }),
+	)
+
+	if err := client.StartContainer(context.Background(), "web", nil); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	if path != "/v1.44/containers/web/start" {
+		t.Errorf("handler saw %s", path)
+	}
+}
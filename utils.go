@@ -1,23 +1,112 @@
 package docker
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
 	"strings"
 )
 
+// ParseURL splits a daemon address into the network and address to dial.
+// unix://, tcp://, http(s):// (dialed over tcp), npipe:// and fd:// are
+// recognized. An address without a scheme is taken as a unix socket if it
+// looks like a path and as a tcp host:port otherwise.
 func ParseURL(url string) (string, string) {
-	arr := strings.Split(url, "://")
+	arr := strings.SplitN(url, "://", 2)
 
 	if len(arr) == 1 {
-		return "unix", arr[0]
+		if strings.HasPrefix(url, "/") || strings.HasPrefix(url, ".") || !strings.Contains(url, ":") {
+			return "unix", url
+		}
+		return "tcp", url
 	}
 
-	proto := arr[0]
-	if proto == "http" {
+	proto, addr := arr[0], arr[1]
+	switch proto {
+	case "http", "https":
 		proto = "tcp"
 	}
 
-	return proto, arr[1]
+	return proto, addr
+}
+
+// checkEndpoint validates a daemon address so that a misconfigured client
+// fails when it is created rather than with a dial error on its first request.
+// npipe:// and fd:// endpoints can only be reached through NewClientWithDialer.
+func checkEndpoint(path string) error {
+	proto, addr := ParseURL(path)
+	if addr == "" {
+		return fmt.Errorf("invalid docker endpoint %q: no address", path)
+	}
+
+	switch proto {
+	case "unix":
+		if _, err := os.Stat(addr); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("docker socket not found: %s", addr)
+			}
+			return err
+		}
+	case "tcp":
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid docker endpoint %q: %v", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported docker endpoint scheme %q in %q", proto, path)
+	}
+	return nil
+}
+
+// validName matches the container, exec and volume names and IDs accepted by
+// the daemon. Container names may carry the leading slash the daemon reports
+// them with.
+var validName = regexp.MustCompile(`^/?[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// checkName rejects names which the daemon would not accept before they are
+// put in a request path.
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid name %q: must match %s", name, validName)
+	}
+	return nil
+}
+
+// pathName returns a name accepted by checkName as it goes in a request path,
+// escaped and without the leading slash of container names.
+func pathName(name string) string {
+	return url.PathEscape(strings.TrimPrefix(name, "/"))
+}
+
+// ParseRepositoryTag splits an image reference into its repository and its tag
+// or digest, e.g. "redis:6.2" into "redis" and "6.2". The tag is empty if the
+// reference has none. A registry port is not mistaken for a tag.
+func ParseRepositoryTag(ref string) (string, string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i+1:], "/") {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+// setFilters JSON-encodes filters into the "filters" parameter of v, which is
+// how the daemon expects them. Nothing is set for empty filters.
+func setFilters(v url.Values, filters map[string][]string) error {
+	if len(filters) == 0 {
+		return nil
+	}
+	buf, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+	v.Set("filters", string(buf))
+	return nil
 }
 
 type readCloseWrapper struct {
@@ -35,3 +124,8 @@ func newReadCloseWrapper(r io.Reader, closer func() error) io.ReadCloser {
 		closer: closer,
 	}
 }
+
+type readWriteCloser struct {
+	io.Reader
+	io.WriteCloser
+}
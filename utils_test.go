@@ -0,0 +1,28 @@
+package docker
+
+import "testing"
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url, proto, addr string
+	}{
+		{"unix:///var/run/docker.sock", "unix", "/var/run/docker.sock"},
+		{"tcp://127.0.0.1:2375", "tcp", "127.0.0.1:2375"},
+		{"http://127.0.0.1:2375", "tcp", "127.0.0.1:2375"},
+		{"https://docker.example.com:2376", "tcp", "docker.example.com:2376"},
+		{"npipe:////./pipe/docker_engine", "npipe", "//./pipe/docker_engine"},
+		{"fd://", "fd", ""},
+		{"/var/run/docker.sock", "unix", "/var/run/docker.sock"},
+		{"./docker.sock", "unix", "./docker.sock"},
+		{"docker.sock", "unix", "docker.sock"},
+		{"127.0.0.1:2375", "tcp", "127.0.0.1:2375"},
+		{"localhost:2375", "tcp", "localhost:2375"},
+		{"[::1]:2375", "tcp", "[::1]:2375"},
+	}
+	for _, tt := range tests {
+		proto, addr := ParseURL(tt.url)
+		if proto != tt.proto || addr != tt.addr {
+			t.Errorf("ParseURL(%q) = %q, %q, want %q, %q", tt.url, proto, addr, tt.proto, tt.addr)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 )
 
+// Volume describes a volume mounted into a container, as returned by
+// Container.GetVolumes.
 type Volume struct {
 	HostPath    string
 	VolPath     string
@@ -13,6 +15,23 @@ type Volume struct {
 	IsBindMount bool
 }
 
+// VolumeInfo is a named volume managed by the daemon, as returned by the
+// volume methods of the client.
+type VolumeInfo struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Labels     map[string]string
+}
+
+// VolumeOptions describes a named volume to be created with CreateVolume.
+type VolumeOptions struct {
+	Name       string            `json:",omitempty"`
+	Driver     string            `json:",omitempty"`
+	DriverOpts map[string]string `json:",omitempty"`
+	Labels     map[string]string `json:",omitempty"`
+}
+
 func (v *Volume) Id() string {
 	return filepath.Base(v.HostPath)
 }